@@ -0,0 +1,83 @@
+package oqsopenssl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Algorithm names a key algorithm as understood by openssl genpkey. The
+// functions taking an algorithm accept any name the openssl build knows, so
+// the constants are a convenience and pass as string(AlgorithmMLDSA44).
+type Algorithm string
+
+const (
+	// Classical algorithms provided by the default provider.
+	AlgorithmRSA     Algorithm = "RSA"
+	AlgorithmRSAPSS  Algorithm = "RSA-PSS"
+	AlgorithmEC      Algorithm = "EC"
+	AlgorithmEd25519 Algorithm = "ED25519"
+	AlgorithmEd448   Algorithm = "ED448"
+
+	// PQ signature algorithms provided by the oqs provider.
+	AlgorithmMLDSA44    Algorithm = "mldsa44"
+	AlgorithmMLDSA65    Algorithm = "mldsa65"
+	AlgorithmMLDSA87    Algorithm = "mldsa87"
+	AlgorithmFalcon512  Algorithm = "falcon512"
+	AlgorithmFalcon1024 Algorithm = "falcon1024"
+
+	// Hybrid algorithms provided by the oqs provider, which sign with a
+	// classical and a PQ key at once. They are used like any other
+	// algorithm, from key generation through CSRs to signing.
+	AlgorithmP256MLDSA44      Algorithm = "p256_mldsa44"
+	AlgorithmRSA3072MLDSA44   Algorithm = "rsa3072_mldsa44"
+	AlgorithmP384MLDSA65      Algorithm = "p384_mldsa65"
+	AlgorithmP521MLDSA87      Algorithm = "p521_mldsa87"
+	AlgorithmP256Falcon512    Algorithm = "p256_falcon512"
+	AlgorithmRSA3072Falcon512 Algorithm = "rsa3072_falcon512"
+	AlgorithmP521Falcon1024   Algorithm = "p521_falcon1024"
+)
+
+// Validate checks that the openssl build can generate keys for a.
+func (a Algorithm) Validate() error {
+	return ValidateAlgorithm(string(a))
+}
+
+// ValidateAlgorithm checks that algorithm is a key algorithm of one of the
+// loaded providers, ignoring case as openssl does. The error for an unknown
+// algorithm lists the available ones.
+func ValidateAlgorithm(algorithm string) error {
+	return validateAlgorithm(context.Background(), algorithm)
+}
+
+// validateAlgorithm is ValidateAlgorithm for a command created with ctx.
+func validateAlgorithm(ctx context.Context, algorithm string) error {
+	if dryRun() {
+		return nil
+	}
+	cmd := opensslCommandContext(ctx, "list", "-key-managers")
+	output, err := runCommandOutput(ctx, cmd, "Failed to list key algorithms")
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		ids, ok := strings.CutPrefix(strings.TrimSpace(line), "IDs: ")
+		if !ok {
+			continue
+		}
+		aliases, _, _ := strings.Cut(ids, " @ ")
+		for _, alias := range strings.Split(strings.Trim(aliases, "{} "), ",") {
+			if strings.EqualFold(strings.TrimSpace(alias), algorithm) {
+				return nil
+			}
+		}
+		for _, entry := range parseAlgorithmList(ids) {
+			names = append(names, entry.Name)
+		}
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown key algorithm %q; available algorithms: %s", algorithm, strings.Join(names, ", "))
+}
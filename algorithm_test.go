@@ -0,0 +1,45 @@
+package oqsopenssl
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHybridCertificateIssuance(t *testing.T) {
+	if err := CheckOQSProvider(); err != nil {
+		t.Skipf("oqs provider not available: %v", err)
+	}
+
+	for _, algorithm := range []Algorithm{AlgorithmP256MLDSA44, AlgorithmP384MLDSA65, AlgorithmP256Falcon512} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			if err := ValidateAlgorithm(string(algorithm)); err != nil {
+				t.Skipf("algorithm not supported by this build: %v", err)
+			}
+			dir := t.TempDir()
+			rootKey := filepath.Join(dir, "root.key")
+			rootCert := filepath.Join(dir, "root.pem")
+			leafKey := filepath.Join(dir, "leaf.key")
+			leafCSR := filepath.Join(dir, "leaf.csr")
+			leafCert := filepath.Join(dir, "leaf.pem")
+
+			if err := GeneratePrivateKey(string(algorithm), rootKey); err != nil {
+				t.Fatalf("GeneratePrivateKey: %v", err)
+			}
+			if err := GenerateRootCertificate(rootKey, rootCert, "/CN=Hybrid Root", "", "", 1); err != nil {
+				t.Fatalf("GenerateRootCertificate: %v", err)
+			}
+			if err := GenerateCSR(string(algorithm), leafKey, leafCSR, "/CN=hybrid-leaf", "spiffe://example.org/hybrid", ""); err != nil {
+				t.Fatalf("GenerateCSR: %v", err)
+			}
+			if err := SignCertificate(leafCSR, rootCert, rootKey, "spiffe://example.org/hybrid", leafCert, 1); err != nil {
+				t.Fatalf("SignCertificate: %v", err)
+			}
+			if err := ValidateCertificate(leafCert, rootCert); err != nil {
+				t.Fatalf("ValidateCertificate: %v", err)
+			}
+			if ok, err := KeyMatchesCertificate(leafKey, leafCert); err != nil || !ok {
+				t.Fatalf("KeyMatchesCertificate = %v, %v; want true", ok, err)
+			}
+		})
+	}
+}
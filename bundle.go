@@ -0,0 +1,103 @@
+package oqsopenssl
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BundlePEM concatenates the PEM blocks of the input files, in order, into
+// outputFile, e.g. to build a -CAfile bundle or a leaf-first fullchain.pem.
+// Every input must contain at least one PEM block; text outside the blocks is
+// dropped. Nothing is written if any input is invalid.
+func BundlePEM(outputFile string, inputs ...string) error {
+	if len(inputs) == 0 {
+		return errors.New("no PEM files to bundle")
+	}
+
+	var bundle bytes.Buffer
+	for _, input := range inputs {
+		blocks, err := readPEMBlocks(input)
+		if err != nil {
+			return err
+		}
+		if err := encodePEMBlocks(&bundle, input, blocks); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(outputFile, bundle.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write PEM bundle: %w", err)
+	}
+	return nil
+}
+
+// WriteCombinedPEM writes the private key in keyFile followed by the
+// certificates in certFile to outputFile, the single-file layout servers such
+// as HAProxy expect. keyFile must hold a PEM private key and certFile at
+// least one PEM certificate. Since the output contains the key, it is only
+// readable by its owner.
+func WriteCombinedPEM(certFile, keyFile, outputFile string) error {
+	keyBlocks, err := readPEMBlocks(keyFile)
+	if err != nil {
+		return err
+	}
+	if len(keyBlocks) != 1 || !strings.HasSuffix(keyBlocks[0].Type, "PRIVATE KEY") {
+		return fmt.Errorf("%s does not contain a single PEM private key", keyFile)
+	}
+	certBlocks, err := readPEMBlocks(certFile)
+	if err != nil {
+		return err
+	}
+	for _, block := range certBlocks {
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("%s contains a %s block instead of a certificate", certFile, block.Type)
+		}
+	}
+
+	var combined bytes.Buffer
+	if err := encodePEMBlocks(&combined, keyFile, keyBlocks); err != nil {
+		return err
+	}
+	if err := encodePEMBlocks(&combined, certFile, certBlocks); err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputFile, combined.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write combined PEM file: %w", err)
+	}
+	return nil
+}
+
+// readPEMBlocks returns the PEM blocks in file, failing if there are none.
+func readPEMBlocks(file string) ([]*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s does not contain any PEM data", file)
+	}
+	return blocks, nil
+}
+
+// encodePEMBlocks writes blocks read from file to buf.
+func encodePEMBlocks(buf *bytes.Buffer, file string, blocks []*pem.Block) error {
+	for _, block := range blocks {
+		if err := pem.Encode(buf, block); err != nil {
+			return fmt.Errorf("failed to encode PEM block from %s: %w", file, err)
+		}
+	}
+	return nil
+}
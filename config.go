@@ -0,0 +1,163 @@
+package oqsopenssl
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Config holds the settings applied to every openssl invocation made by the package.
+type Config struct {
+	// OpenSSLPath is the openssl binary to run, e.g. /opt/oqssa/bin/openssl.
+	// If empty, "openssl" is looked up on PATH.
+	OpenSSLPath string
+
+	// Env holds environment variables set for every openssl process, on top
+	// of the environment inherited from the current process, e.g.
+	// OPENSSL_CONF and OPENSSL_MODULES pointing at the configuration and
+	// provider directory matching OpenSSLPath.
+	Env map[string]string
+
+	// ServerStartTimeout bounds how long StartServer waits for s_server to
+	// start listening. If zero, DefaultServerStartTimeout is used.
+	ServerStartTimeout time.Duration
+
+	// StopGracePeriod is how long StopServer waits after asking s_server to
+	// exit before killing it. If zero, DefaultStopGracePeriod is used.
+	StopGracePeriod time.Duration
+
+	// HandshakeTimeout bounds how long Handshake waits for a handshake to
+	// complete. If zero, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+
+	// Logger receives diagnostic output. If nil, output is discarded.
+	Logger Logger
+
+	// ProviderName is the provider CheckOQSProvider looks for. If empty, any
+	// of DefaultProviderNames is accepted.
+	ProviderName string
+
+	// DryRun makes every function return a *DryRunError holding the openssl
+	// command line instead of running it, e.g. to reproduce a call by hand.
+	// Functions that run several commands stop at the first one, and
+	// temporary files named on the command line are already removed.
+	// Checks of algorithm and digest names against the openssl build are
+	// skipped, so the command doing the actual work is reported.
+	DryRun bool
+}
+
+const (
+	// DefaultServerStartTimeout is used when Config.ServerStartTimeout is zero.
+	DefaultServerStartTimeout = 10 * time.Second
+
+	// DefaultStopGracePeriod is used when Config.StopGracePeriod is zero.
+	DefaultStopGracePeriod = 5 * time.Second
+
+	// DefaultHandshakeTimeout is used when Config.HandshakeTimeout is zero.
+	DefaultHandshakeTimeout = 10 * time.Second
+)
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// SetConfig replaces the package configuration.
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// GetConfig returns a copy of the current package configuration.
+func GetConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// SetOpenSSLPath sets the openssl binary used by all functions.
+// An empty path restores the default of "openssl" found on PATH.
+func SetOpenSSLPath(path string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config.OpenSSLPath = path
+}
+
+// opensslPath returns the configured openssl binary.
+func opensslPath() string {
+	if path := GetConfig().OpenSSLPath; path != "" {
+		return path
+	}
+	return "openssl"
+}
+
+// opensslCommand builds an exec.Cmd running the configured openssl binary.
+func opensslCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(opensslPath(), args...)
+	cmd.Env = opensslEnv()
+	return cmd
+}
+
+// opensslCommandContext is like opensslCommand but kills the process when ctx is done.
+func opensslCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, opensslPath(), args...)
+	cmd.Env = opensslEnv()
+	return cmd
+}
+
+// opensslEnv returns the environment for openssl processes. A nil result
+// makes exec inherit the current environment unchanged.
+func opensslEnv() []string {
+	extra := GetConfig().Env
+	if len(extra) == 0 {
+		return nil
+	}
+	env := os.Environ()
+	for name, value := range extra {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// serverStartTimeout returns the configured server start timeout.
+func serverStartTimeout() time.Duration {
+	if timeout := GetConfig().ServerStartTimeout; timeout > 0 {
+		return timeout
+	}
+	return DefaultServerStartTimeout
+}
+
+// stopGracePeriod returns the configured stop grace period.
+func stopGracePeriod() time.Duration {
+	if grace := GetConfig().StopGracePeriod; grace > 0 {
+		return grace
+	}
+	return DefaultStopGracePeriod
+}
+
+// handshakeTimeout returns the configured handshake timeout.
+func handshakeTimeout() time.Duration {
+	if timeout := GetConfig().HandshakeTimeout; timeout > 0 {
+		return timeout
+	}
+	return DefaultHandshakeTimeout
+}
+
+// dryRun reports whether Config.DryRun is set.
+func dryRun() bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config.DryRun
+}
+
+// dryRunError returns a *DryRunError for cmd if Config.DryRun is set, and nil
+// otherwise.
+func dryRunError(cmd *exec.Cmd) error {
+	if !dryRun() {
+		return nil
+	}
+	return &DryRunError{Args: cmd.Args}
+}
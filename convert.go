@@ -0,0 +1,79 @@
+package oqsopenssl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ConvertCertPEMToDER converts the certificate in inputFile to DER.
+func ConvertCertPEMToDER(inputFile, outputFile string) error {
+	return convertFormat("x509", inputFile, outputFile, "DER")
+}
+
+// ConvertCertDERToPEM converts the certificate in inputFile to PEM.
+func ConvertCertDERToPEM(inputFile, outputFile string) error {
+	return convertFormat("x509", inputFile, outputFile, "PEM")
+}
+
+// ConvertKeyPEMToDER converts the private key in inputFile to DER.
+func ConvertKeyPEMToDER(inputFile, outputFile string) error {
+	return convertFormat("pkey", inputFile, outputFile, "DER")
+}
+
+// ConvertKeyDERToPEM converts the private key in inputFile to PEM.
+func ConvertKeyDERToPEM(inputFile, outputFile string) error {
+	return convertFormat("pkey", inputFile, outputFile, "PEM")
+}
+
+// ConvertToPKCS8 rewrites the unencrypted private key in inputFile as a PEM
+// PKCS#8 key, the format Java and other strict consumers require. PQ keys
+// are supported as far as their provider can encode them. If encrypt is set,
+// the key is encrypted with AES-256 under passphrase, which is fed to openssl
+// on stdin so it never shows up in the process list.
+func ConvertToPKCS8(inputFile, outputFile string, encrypt bool, passphrase io.Reader) error {
+	inform, err := detectFormat(inputFile)
+	if err != nil {
+		return err
+	}
+	args := []string{"pkcs8", "-topk8", "-inform", inform, "-in", inputFile, "-out", outputFile}
+	if encrypt {
+		if passphrase == nil {
+			return errors.New("passphrase is required to encrypt the key")
+		}
+		args = append(args, "-v2", "aes256", "-passout", "stdin")
+	} else {
+		args = append(args, "-nocrypt")
+	}
+	cmd := opensslCommand(args...)
+	if encrypt {
+		cmd.Stdin = passphrase
+	}
+	return runCommand(cmd, "Failed to convert key to PKCS#8")
+}
+
+// convertFormat runs the x509 or pkey subcommand to rewrite inputFile in the
+// given output format. The input format is detected from the file contents,
+// so an input already in the target format is simply rewritten.
+func convertFormat(subcommand, inputFile, outputFile, outform string) error {
+	inform, err := detectFormat(inputFile)
+	if err != nil {
+		return err
+	}
+	cmd := opensslCommand(subcommand, "-inform", inform, "-in", inputFile, "-outform", outform, "-out", outputFile)
+	return runCommand(cmd, fmt.Sprintf("Failed to convert %s to %s", inputFile, outform))
+}
+
+// detectFormat returns "PEM" if the file contains a PEM header and "DER" otherwise.
+func detectFormat(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return "PEM", nil
+	}
+	return "DER", nil
+}
@@ -0,0 +1,104 @@
+package oqsopenssl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GenerateCRL writes a certificate revocation list signed by the CA to
+// outputFile, valid for the given number of days. indexFile is the openssl ca
+// database listing revoked certificates, as maintained by RevokeCertificate;
+// it is created empty if it does not exist, giving a CRL with no entries.
+func GenerateCRL(caCertFile, caKeyFile, indexFile, outputFile string, days int) error {
+	return GenerateCRLWithOptions(caCertFile, caKeyFile, indexFile, outputFile, CRLOptions{Days: days})
+}
+
+// CRLOptions holds the settings for GenerateCRLWithOptions and
+// RevokeCertificateWithOptions.
+type CRLOptions struct {
+	// Days is the validity period of the CRL. It is not used when revoking.
+	Days int
+
+	// Digest is the message digest used to sign the CRL, e.g. DigestSHA384,
+	// as with SignOptions.Digest. If empty, the CA key picks its default.
+	Digest DigestAlgorithm
+}
+
+// GenerateCRLWithOptions is like GenerateCRL but takes the validity period
+// and signing digest from opts.
+func GenerateCRLWithOptions(caCertFile, caKeyFile, indexFile, outputFile string, opts CRLOptions) error {
+	if opts.Days <= 0 {
+		return fmt.Errorf("invalid CRL validity of %d days", opts.Days)
+	}
+	if err := checkInputFiles(caCertFile, caKeyFile); err != nil {
+		return err
+	}
+	configFile, cleanup, err := writeCAConfig(indexFile, opts.Digest)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := opensslCommand("ca", "-batch", "-config", configFile, "-cert", caCertFile, "-keyfile", caKeyFile,
+		"-gencrl", "-crldays", strconv.Itoa(opts.Days), "-out", outputFile)
+	return runCommand(cmd, "Failed to generate CRL")
+}
+
+// RevokeCertificate records certFile as revoked in indexFile, so that the
+// next GenerateCRL for the CA lists it. indexFile is created if it does not
+// exist.
+func RevokeCertificate(caCertFile, caKeyFile, indexFile, certFile string) error {
+	return RevokeCertificateWithOptions(caCertFile, caKeyFile, indexFile, certFile, CRLOptions{})
+}
+
+// RevokeCertificateWithOptions is like RevokeCertificate but configures the
+// CA with the digest from opts, so that it matches the one used for the CRL.
+func RevokeCertificateWithOptions(caCertFile, caKeyFile, indexFile, certFile string, opts CRLOptions) error {
+	if err := checkInputFiles(caCertFile, caKeyFile, certFile); err != nil {
+		return err
+	}
+	configFile, cleanup, err := writeCAConfig(indexFile, opts.Digest)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := opensslCommand("ca", "-batch", "-config", configFile, "-cert", caCertFile, "-keyfile", caKeyFile,
+		"-revoke", certFile)
+	return runCommand(cmd, "Failed to revoke certificate")
+}
+
+// writeCAConfig writes the minimal openssl ca configuration needed to revoke
+// certificates and generate CRLs against indexFile, creating the index if
+// necessary. If digest is empty, the CA key picks its digest.
+func writeCAConfig(indexFile string, digest DigestAlgorithm) (path string, cleanup func(), err error) {
+	md := DigestAlgorithm("default")
+	if digest != "" {
+		if err := validateSigningDigest(digest); err != nil {
+			return "", nil, err
+		}
+		md = digest.canonical()
+	}
+
+	if !dryRun() {
+		index, err := os.OpenFile(indexFile, os.O_RDONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create CA index file: %w", err)
+		}
+		index.Close()
+	}
+
+	var b strings.Builder
+	b.WriteString("[ ca ]\n")
+	b.WriteString("default_ca = ca_default\n")
+	b.WriteString("\n[ ca_default ]\n")
+	if err := writeConfigValue(&b, "database", indexFile); err != nil {
+		return "", nil, err
+	}
+	// By default let the key pick its digest; PQ signature algorithms take
+	// none
+	fmt.Fprintf(&b, "default_md = %s\n", md)
+	return writeTempConfig(b.String())
+}
@@ -0,0 +1,109 @@
+package oqsopenssl
+
+import (
+	"context"
+	"strings"
+)
+
+// CSRInfo holds the main fields of a certificate signing request.
+type CSRInfo struct {
+	// Subject is the requested distinguished name, e.g. "CN = server".
+	Subject string
+
+	// PublicKeyAlgorithm is the algorithm of the requested key, e.g.
+	// "id-ecPublicKey" or "mldsa65".
+	PublicKeyAlgorithm string
+
+	// SignatureAlgorithm is the algorithm of the request self-signature.
+	SignatureAlgorithm string
+
+	// Extensions maps the name of each requested extension, as printed by
+	// openssl, e.g. "X509v3 Key Usage", to its value. Multi-line values are
+	// joined with newlines.
+	Extensions map[string]string
+
+	// SANs are the requested subjectAltName entries.
+	SANs []SAN
+
+	// SPIFFEID is the first requested URI SAN with the spiffe scheme, if any.
+	SPIFFEID string
+}
+
+// VerifyCSR checks the self-signature of the certificate signing request in
+// csrFile, which proves the requester holds the private key.
+func VerifyCSR(csrFile string) error {
+	cmd := opensslCommand("req", "-in", csrFile, "-noout", "-verify")
+	return runCommand(cmd, "Failed to verify CSR")
+}
+
+// ParseCSR reads the subject, key and signature algorithms and requested
+// extensions of the certificate signing request in csrFile. The signature is
+// not checked; use VerifyCSR for that.
+func ParseCSR(csrFile string) (*CSRInfo, error) {
+	cmd := opensslCommand("req", "-in", csrFile, "-noout", "-text")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to parse CSR")
+	if err != nil {
+		return nil, err
+	}
+
+	info := parseCSRText(output)
+	info.SANs = parseSANList(info.Extensions["X509v3 Subject Alternative Name"])
+	info.SPIFFEID = firstSPIFFEID(info.SANs)
+	return info, nil
+}
+
+// parseCSRText parses the output of openssl req -text. Requested extensions
+// are listed one indentation level below "Requested Extensions:", with their
+// values indented further.
+func parseCSRText(output string) *CSRInfo {
+	info := &CSRInfo{Extensions: map[string]string{}}
+	inExtensions := false
+	extIndent := -1
+	var extName string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if trimmed == "Requested Extensions:" {
+			inExtensions = true
+			continue
+		}
+		if inExtensions {
+			if extIndent < 0 {
+				extIndent = indent
+			}
+			switch {
+			case indent == extIndent:
+				// "X509v3 Key Usage: critical" names the extension
+				extName, _, _ = strings.Cut(trimmed, ":")
+				info.Extensions[extName] = ""
+				continue
+			case indent > extIndent:
+				if value := info.Extensions[extName]; value != "" {
+					info.Extensions[extName] = value + "\n" + trimmed
+				} else {
+					info.Extensions[extName] = trimmed
+				}
+				continue
+			}
+			inExtensions = false
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Subject":
+			info.Subject = value
+		case "Public Key Algorithm":
+			info.PublicKeyAlgorithm = value
+		case "Signature Algorithm":
+			info.SignatureAlgorithm = value
+		}
+	}
+	return info
+}
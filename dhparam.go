@@ -0,0 +1,19 @@
+package oqsopenssl
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GenerateDHParams writes new Diffie-Hellman parameters of the given size in
+// bits to outputFile, for use with WithDHParams. At the default security
+// level s_server rejects parameters smaller than 2048 bits. Finding safe
+// primes is slow, 2048 bits can take from seconds to minutes, so tests should
+// generate them once and reuse the file.
+func GenerateDHParams(bits int, outputFile string) error {
+	if bits <= 0 {
+		return fmt.Errorf("invalid DH parameter size of %d bits", bits)
+	}
+	cmd := opensslCommand("dhparam", "-out", outputFile, strconv.Itoa(bits))
+	return runCommand(cmd, "Failed to generate DH parameters")
+}
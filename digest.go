@@ -0,0 +1,104 @@
+package oqsopenssl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DigestAlgorithm names a message digest as understood by openssl, e.g. for
+// SignOptions.Digest and Fingerprint. Names are matched case-insensitively
+// against the aliases openssl lists, so "SHA256" and "sha2-256" both work.
+type DigestAlgorithm string
+
+const (
+	DigestSHA1     DigestAlgorithm = "sha1"
+	DigestSHA224   DigestAlgorithm = "sha224"
+	DigestSHA256   DigestAlgorithm = "sha256"
+	DigestSHA384   DigestAlgorithm = "sha384"
+	DigestSHA512   DigestAlgorithm = "sha512"
+	DigestSHA3_224 DigestAlgorithm = "sha3-224"
+	DigestSHA3_256 DigestAlgorithm = "sha3-256"
+	DigestSHA3_384 DigestAlgorithm = "sha3-384"
+	DigestSHA3_512 DigestAlgorithm = "sha3-512"
+)
+
+// Validate checks that the openssl build provides the digest d. The error for
+// an unknown digest lists the available ones.
+func (d DigestAlgorithm) Validate() error {
+	return validateDigest(context.Background(), d)
+}
+
+// flag returns the command line flag selecting d, e.g. "-sha256".
+func (d DigestAlgorithm) flag() string {
+	return "-" + string(d.canonical())
+}
+
+// canonical returns d in lower case, with the SHA-1 and SHA-2 aliases such as
+// "SHA2-256" and "sha-256" mapped to the short form, e.g. "sha256".
+func (d DigestAlgorithm) canonical() DigestAlgorithm {
+	name := strings.ToLower(string(d))
+	for _, prefix := range []string{"sha2-", "sha-"} {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		switch rest {
+		case "1", "224", "256", "384", "512":
+			return DigestAlgorithm("sha" + rest)
+		}
+	}
+	return DigestAlgorithm(name)
+}
+
+// validateSigningDigest checks that d may be used to sign certificates and
+// CRLs: SHA-1 is rejected, and the openssl build must provide the digest.
+func validateSigningDigest(d DigestAlgorithm) error {
+	if !signingDigests[d.canonical()] {
+		return fmt.Errorf("unsupported signing digest %q", d)
+	}
+	return d.Validate()
+}
+
+// signingDigests are the digests accepted for signing, in canonical form.
+var signingDigests = map[DigestAlgorithm]bool{
+	DigestSHA224:   true,
+	DigestSHA256:   true,
+	DigestSHA384:   true,
+	DigestSHA512:   true,
+	DigestSHA3_224: true,
+	DigestSHA3_256: true,
+	DigestSHA3_384: true,
+	DigestSHA3_512: true,
+}
+
+// validateDigest is DigestAlgorithm.Validate for a command created with ctx.
+func validateDigest(ctx context.Context, d DigestAlgorithm) error {
+	if dryRun() {
+		return nil
+	}
+	cmd := opensslCommandContext(ctx, "list", "-digest-algorithms")
+	output, err := runCommandOutput(ctx, cmd, "Failed to list digest algorithms")
+	if err != nil {
+		return err
+	}
+
+	// Only the provided section lists what can actually be fetched; the
+	// legacy section above it also names digests of disabled providers
+	_, provided, _ := strings.Cut(output, "Provided:")
+	var names []string
+	for _, line := range strings.Split(provided, "\n") {
+		aliases, _, _ := strings.Cut(strings.TrimSpace(line), " @ ")
+		for _, alias := range strings.Split(strings.Trim(aliases, "{} "), ",") {
+			if strings.EqualFold(strings.TrimSpace(alias), string(d)) {
+				return nil
+			}
+		}
+	}
+	for _, entry := range parseAlgorithmList(provided) {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown digest %q; available digests: %s", d, strings.Join(names, ", "))
+}
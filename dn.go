@@ -0,0 +1,46 @@
+package oqsopenssl
+
+import "strings"
+
+// DN is a distinguished name whose String method renders the -subj argument
+// taken by GenerateRootCertificate, GenerateCSR and the other functions with a
+// subject. Empty attributes are left out.
+type DN struct {
+	Country            string
+	Province           string
+	Locality           string
+	Organization       string
+	OrganizationalUnit string
+	CommonName         string
+	EmailAddress       string
+}
+
+// subjEscaper escapes the characters -subj parsing treats specially: the
+// attribute separator, the multi-valued RDN separator, the separator between
+// attribute type and value, and the escape itself.
+var subjEscaper = strings.NewReplacer(`\`, `\\`, `/`, `\/`, `+`, `\+`, `=`, `\=`)
+
+// String returns d in -subj notation, e.g. "/O=Example/CN=a\/b", with the
+// attributes in the conventional C, ST, L, O, OU, CN order. An empty DN gives
+// "/", which openssl reads as an empty subject.
+func (d DN) String() string {
+	values := map[string]string{
+		"C":            d.Country,
+		"ST":           d.Province,
+		"L":            d.Locality,
+		"O":            d.Organization,
+		"OU":           d.OrganizationalUnit,
+		"CN":           d.CommonName,
+		"emailAddress": d.EmailAddress,
+	}
+	var b strings.Builder
+	for _, attr := range dnOrder {
+		if value := values[attr]; value != "" {
+			b.WriteString("/" + attr + "=" + subjEscaper.Replace(value))
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
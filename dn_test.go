@@ -0,0 +1,26 @@
+package oqsopenssl
+
+import "testing"
+
+func TestDNString(t *testing.T) {
+	tests := []struct {
+		name string
+		dn   DN
+		want string
+	}{
+		{"empty", DN{}, "/"},
+		{"order", DN{CommonName: "server", Organization: "Example", Country: "DE"}, "/C=DE/O=Example/CN=server"},
+		{"slash", DN{CommonName: "a/b"}, `/CN=a\/b`},
+		{"plus", DN{CommonName: "a+b"}, `/CN=a\+b`},
+		{"equals", DN{CommonName: "a=b"}, `/CN=a\=b`},
+		{"backslash", DN{CommonName: `a\b`}, `/CN=a\\b`},
+		{"escaped separator", DN{Organization: `x\/y`}, `/O=x\\\/y`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dn.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
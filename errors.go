@@ -0,0 +1,216 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrOpenSSLNotFound is wrapped by errors caused by the openssl binary being
+// missing from PATH or from the path set with SetOpenSSLPath.
+var ErrOpenSSLNotFound = errors.New("openssl binary not found")
+
+// ErrFileNotFound is wrapped when a required input file does not exist. It
+// is checked before openssl runs, whose own error would be less clear.
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrConnectTimeout is wrapped when a client could not establish a connection
+// within ClientOptions.ConnectTimeout.
+var ErrConnectTimeout = errors.New("connection timed out")
+
+// ErrConnectionRefused is wrapped when s_client could not connect because
+// nothing was listening at the address.
+var ErrConnectionRefused = errors.New("connection refused")
+
+// ErrServerNotReady is wrapped when s_server exits or times out before it
+// starts accepting connections.
+var ErrServerNotReady = errors.New("server not ready")
+
+// ErrCertificateRevoked is matched by a *VerifyError when a CRL lists a
+// certificate in the chain as revoked.
+var ErrCertificateRevoked = errors.New("certificate revoked")
+
+// ErrInvalidPurpose is matched by a *VerifyError when a certificate in the
+// chain may not be used for VerifyOptions.Purpose.
+var ErrInvalidPurpose = errors.New("certificate not valid for purpose")
+
+// ErrNoSPIFFEID is returned by VerifySPIFFEID when the certificate has no
+// spiffe URI SAN.
+var ErrNoSPIFFEID = errors.New("certificate has no SPIFFE ID")
+
+// ErrTrustDomainMismatch is wrapped by VerifySPIFFEID when the certificate's
+// SPIFFE ID belongs to a different trust domain than expected.
+var ErrTrustDomainMismatch = errors.New("SPIFFE trust domain mismatch")
+
+// ErrSPIFFEIDMismatch is wrapped by VerifySPIFFEID when the certificate's
+// SPIFFE ID is in the expected trust domain but differs from the expected ID.
+var ErrSPIFFEIDMismatch = errors.New("SPIFFE ID mismatch")
+
+// ErrBadPassphrase is wrapped by errors caused by a passphrase that does not
+// decrypt the key it was supplied for.
+var ErrBadPassphrase = errors.New("incorrect passphrase")
+
+// OpenSSLError is returned when an openssl command exits with a non-zero status.
+// Use errors.As to inspect it, e.g. openssl verify exits with 2 when a
+// certificate fails validation.
+type OpenSSLError struct {
+	// ExitCode is the exit status reported by openssl.
+	ExitCode int
+	// Stderr holds the diagnostic output of the command.
+	Stderr string
+	// Args are the arguments passed to openssl, without the binary itself.
+	Args []string
+}
+
+// Error implements the error interface.
+func (e *OpenSSLError) Error() string {
+	subcommand := "command"
+	if len(e.Args) > 0 {
+		subcommand = e.Args[0]
+	}
+	return fmt.Sprintf("openssl %s exited with status %d\n%s", subcommand, e.ExitCode, strings.TrimRight(e.Stderr, "\n"))
+}
+
+// wrapNotFound wraps err with ErrOpenSSLNotFound if the openssl binary could
+// not be started because it does not exist.
+func wrapNotFound(err error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrOpenSSLNotFound, err)
+	}
+	return err
+}
+
+// checkInputFiles returns an error wrapping ErrFileNotFound for the first of
+// paths that is empty or does not exist. Other stat errors are left for
+// openssl to report. The check is skipped in dry-run mode.
+func checkInputFiles(paths ...string) error {
+	if dryRun() {
+		return nil
+	}
+	for _, path := range paths {
+		if path == "" {
+			return fmt.Errorf("%w: no path given", ErrFileNotFound)
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrFileNotFound, path)
+		}
+	}
+	return nil
+}
+
+// classifyPassphraseError wraps err with ErrBadPassphrase if openssl reported
+// that it could not decrypt a key.
+func classifyPassphraseError(err error) error {
+	var opensslErr *OpenSSLError
+	if errors.As(err, &opensslErr) &&
+		(strings.Contains(opensslErr.Stderr, "bad decrypt") || strings.Contains(opensslErr.Stderr, "maybe wrong password")) {
+		return fmt.Errorf("%w: %w", ErrBadPassphrase, err)
+	}
+	return err
+}
+
+// DryRunError is returned instead of running openssl when Config.DryRun is
+// set.
+type DryRunError struct {
+	// Args is the full command line, starting with the openssl binary.
+	Args []string
+}
+
+// Error implements the error interface.
+func (e *DryRunError) Error() string {
+	return "dry run: " + e.CommandLine()
+}
+
+// CommandLine returns the command quoted for a POSIX shell.
+func (e *DryRunError) CommandLine() string {
+	quoted := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// VerifyError describes the chain link that failed openssl verify, or the
+// peer certificate that failed verification during a handshake.
+type VerifyError struct {
+	// Depth is the position of the failing certificate in the chain, where
+	// 0 is the certificate being verified.
+	Depth int
+	// Subject is the subject of the failing certificate, if reported.
+	Subject string
+	// Code is the X509_V_ERR verify error code, e.g. 20.
+	Code int
+	// Reason is openssl's description of the error, e.g.
+	// "unable to get local issuer certificate".
+	Reason string
+	// Err is the underlying command error, if any.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *VerifyError) Error() string {
+	msg := fmt.Sprintf("verification failed at depth %d", e.Depth)
+	if e.Subject != "" {
+		msg += fmt.Sprintf(" (%s)", e.Subject)
+	}
+	return fmt.Sprintf("%s: error %d: %s", msg, e.Code, e.Reason)
+}
+
+// Unwrap returns the underlying command error.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether a revoked certificate matches ErrCertificateRevoked and
+// a purpose mismatch matches ErrInvalidPurpose.
+func (e *VerifyError) Is(target error) bool {
+	switch target {
+	case ErrCertificateRevoked:
+		return e.Code == VerifyErrCertRevoked
+	case ErrInvalidPurpose:
+		return e.Code == VerifyErrInvalidPurpose
+	}
+	return false
+}
+
+// classifyVerifyError turns a failed openssl verify into a *VerifyError when
+// the output names the failing certificate.
+func classifyVerifyError(err error) error {
+	var opensslErr *OpenSSLError
+	if !errors.As(err, &opensslErr) {
+		return err
+	}
+	if verifyErr := parseVerifyError(opensslErr.Stderr); verifyErr != nil {
+		verifyErr.Err = err
+		return verifyErr
+	}
+	return err
+}
+
+// parseVerifyError finds the first "error N at D depth lookup: reason" line in
+// openssl verify output. The line before it holds the certificate subject.
+func parseVerifyError(output string) *VerifyError {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		var code, depth int
+		if _, err := fmt.Sscanf(line, "error %d at %d depth lookup:", &code, &depth); err != nil {
+			continue
+		}
+		verifyErr := &VerifyError{Depth: depth, Code: code}
+		if _, reason, ok := strings.Cut(line, "lookup:"); ok {
+			verifyErr.Reason = strings.TrimSpace(reason)
+		}
+		if i > 0 && !strings.HasPrefix(lines[i-1], "error ") {
+			verifyErr.Subject = strings.TrimSpace(lines[i-1])
+		}
+		return verifyErr
+	}
+	return nil
+}
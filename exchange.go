@@ -0,0 +1,112 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// exchangeIdleTime is how long SendAndReceive waits for more data after the
+// last chunk before it considers the reply complete.
+const exchangeIdleTime = 200 * time.Millisecond
+
+// SendAndReceive writes payload to a client's stdin, as returned by
+// StartClient, and collects what arrives on its stdout until the output has
+// been quiet for a short while, the connection closes, or timeout elapses.
+// A -www server answers "GET / HTTP/1.0\r\n\r\n" with an HTTP response and
+// then closes the connection, so the full response is returned.
+//
+// The reply includes anything s_client printed itself that has not been read
+// yet, such as the session summary after the handshake. Note that s_client
+// treats input lines starting with "Q", "R" or "k" as commands unless it is
+// started with -nocommands in ClientOptions.ExtraArgs.
+//
+// stdout is read in the background while SendAndReceive runs, and output
+// arriving just after it returns may be consumed and dropped. For several
+// exchanges over one connection use ClientHandle.SendAndReceive, which keeps
+// that output for the next call.
+func SendAndReceive(stdin io.WriteCloser, stdout io.Reader, payload []byte, timeout time.Duration) ([]byte, error) {
+	p := startReadPump(stdout)
+	defer p.stop()
+	return p.exchange(stdin, payload, timeout)
+}
+
+// readPump reads from a reader in the background so reads can time out.
+type readPump struct {
+	chunks   chan []byte
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// err is the error that ended reading, valid once chunks is closed.
+	err error
+}
+
+// startReadPump starts reading r until it fails or stop is called.
+func startReadPump(r io.Reader) *readPump {
+	p := &readPump{
+		chunks:  make(chan []byte, 16),
+		stopped: make(chan struct{}),
+	}
+	go p.run(r)
+	return p
+}
+
+// run reads r until it fails, then closes the chunks channel. After stop it
+// returns as soon as the pending read completes.
+func (p *readPump) run(r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			select {
+			case p.chunks <- append([]byte(nil), buf[:n]...):
+			case <-p.stopped:
+				return
+			}
+		}
+		if err != nil {
+			p.err = err
+			close(p.chunks)
+			return
+		}
+	}
+}
+
+// stop makes run return once its pending read completes. It may be called
+// more than once.
+func (p *readPump) stop() {
+	p.stopOnce.Do(func() { close(p.stopped) })
+}
+
+// exchange implements SendAndReceive on the output read by p.
+func (p *readPump) exchange(stdin io.Writer, payload []byte, timeout time.Duration) ([]byte, error) {
+	if _, err := stdin.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to send payload: %w", err)
+	}
+
+	var reply []byte
+	var idle <-chan time.Time
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case chunk, ok := <-p.chunks:
+			if !ok {
+				if p.err != nil && !errors.Is(p.err, io.EOF) {
+					return reply, fmt.Errorf("failed to read reply: %w", p.err)
+				}
+				return reply, nil
+			}
+			reply = append(reply, chunk...)
+			idle = time.After(exchangeIdleTime)
+		case <-idle:
+			return reply, nil
+		case <-deadline.C:
+			if len(reply) == 0 {
+				return nil, fmt.Errorf("no reply within %s", timeout)
+			}
+			return reply, nil
+		}
+	}
+}
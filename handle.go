@@ -0,0 +1,169 @@
+package oqsopenssl
+
+import (
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ServerHandle manages the lifecycle of an s_server process started by
+// StartServerHandle.
+type ServerHandle struct {
+	// Cmd is the underlying command, for callers that need direct access.
+	// Do not call Cmd.Wait; use Wait, Stop or Close instead.
+	Cmd *exec.Cmd
+
+	// Stdin and Stdout are the pipes returned by StartServerWithOptions.
+	// Stdout also carries s_server's stderr.
+	Stdin  io.WriteCloser
+	Stdout io.ReadCloser
+
+	// Port is the port the server is bound to.
+	Port int
+
+	state processState
+}
+
+// StartServerHandle is like StartServerWithOptions but returns a
+// ServerHandle.
+func StartServerHandle(opts ...ServerOption) (*ServerHandle, error) {
+	cmd, stdin, stdout, port, err := StartServerWithOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerHandle{Cmd: cmd, Stdin: stdin, Stdout: stdout, Port: port}, nil
+}
+
+// PID returns the process ID of the server.
+func (h *ServerHandle) PID() int {
+	return h.Cmd.Process.Pid
+}
+
+// Wait blocks until the server exits and returns its exit status. It may be
+// called any number of times, also concurrently with Stop.
+func (h *ServerHandle) Wait() error {
+	return h.state.wait(h.Cmd)
+}
+
+// Stop stops the server like StopServer. It returns nil if the server has
+// already exited.
+func (h *ServerHandle) Stop() error {
+	return h.state.stop(h.Cmd)
+}
+
+// Close stops the server and closes both pipes, releasing everything the
+// handle holds, so it can be deferred right after StartServerHandle.
+func (h *ServerHandle) Close() error {
+	return h.state.close(h.Cmd, h.Stdin, h.Stdout)
+}
+
+// ClientHandle manages the lifecycle of an s_client process started by
+// StartClientHandle.
+type ClientHandle struct {
+	// Cmd is the underlying command, for callers that need direct access.
+	// Do not call Cmd.Wait; use Wait, Stop or Close instead.
+	Cmd *exec.Cmd
+
+	// Stdin and Stdout are the pipes returned by StartClientWithOptions.
+	// Stdout also carries s_client's stderr.
+	Stdin  io.WriteCloser
+	Stdout io.ReadCloser
+
+	state    processState
+	pumpOnce sync.Once
+	pump     *readPump
+}
+
+// StartClientHandle is like StartClientWithOptions but returns a
+// ClientHandle.
+func StartClientHandle(address string, opts ClientOptions) (*ClientHandle, error) {
+	cmd, stdin, stdout, err := StartClientWithOptions(address, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientHandle{Cmd: cmd, Stdin: stdin, Stdout: stdout}, nil
+}
+
+// PID returns the process ID of the client.
+func (h *ClientHandle) PID() int {
+	return h.Cmd.Process.Pid
+}
+
+// Wait blocks until the client exits and returns its exit status. It may be
+// called any number of times, also concurrently with Stop.
+func (h *ClientHandle) Wait() error {
+	return h.state.wait(h.Cmd)
+}
+
+// Stop stops the client like StopServer stops a server. It returns nil if
+// the client has already exited.
+func (h *ClientHandle) Stop() error {
+	return h.state.stop(h.Cmd)
+}
+
+// SendAndReceive is like the package-level SendAndReceive on the client's
+// pipes. Stdout is read in the background from the first call until Close,
+// and output arriving between calls is returned by the next one, so Stdout
+// must not be read directly once SendAndReceive has been used.
+func (h *ClientHandle) SendAndReceive(payload []byte, timeout time.Duration) ([]byte, error) {
+	h.pumpOnce.Do(func() { h.pump = startReadPump(h.Stdout) })
+	return h.pump.exchange(h.Stdin, payload, timeout)
+}
+
+// Close stops the client and closes both pipes, releasing everything the
+// handle holds, so it can be deferred right after StartClientHandle.
+func (h *ClientHandle) Close() error {
+	err := h.state.close(h.Cmd, h.Stdin, h.Stdout)
+	// Once Do returns, pump is either set or never will be
+	h.pumpOnce.Do(func() {})
+	if h.pump != nil {
+		h.pump.stop()
+	}
+	return err
+}
+
+// processState waits on a handle's process exactly once, however many
+// callers there are.
+type processState struct {
+	once    sync.Once
+	done    chan struct{}
+	waitErr error
+}
+
+// exited returns a channel that is closed once cmd has exited.
+func (s *processState) exited(cmd *exec.Cmd) <-chan struct{} {
+	s.once.Do(func() {
+		s.done = make(chan struct{})
+		go func() {
+			s.waitErr = cmd.Wait()
+			close(s.done)
+		}()
+	})
+	return s.done
+}
+
+// wait blocks until cmd has exited and returns its exit status.
+func (s *processState) wait(cmd *exec.Cmd) error {
+	<-s.exited(cmd)
+	return s.waitErr
+}
+
+// stop terminates cmd and waits for it to exit.
+func (s *processState) stop(cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.wait(cmd)
+	}()
+	return stopProcess(cmd.Process, done)
+}
+
+// close closes stdin, stops cmd and closes stdout. Errors from closing the
+// pipes are ignored, since waiting on the process may already have closed
+// them.
+func (s *processState) close(cmd *exec.Cmd, stdin io.WriteCloser, stdout io.ReadCloser) error {
+	_ = stdin.Close()
+	err := s.stop(cmd)
+	_ = stdout.Close()
+	return err
+}
@@ -0,0 +1,507 @@
+package oqsopenssl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrHostnameMismatch is wrapped when the server certificate does not match
+// the hostname the client was asked to verify.
+var ErrHostnameMismatch = errors.New("server certificate does not match hostname")
+
+// ErrNoSharedGroup is wrapped when the peers have no key exchange group in
+// common. Only the server can tell this apart from other handshake failures;
+// the client just receives a handshake_failure alert.
+var ErrNoSharedGroup = errors.New("no shared key exchange group")
+
+// ErrNoSharedCipher is wrapped when the peers have no cipher suite in common.
+var ErrNoSharedCipher = errors.New("no shared cipher")
+
+// ErrCertificateVerify is wrapped when a peer certificate failed
+// verification, whether or not the handshake was aborted because of it.
+var ErrCertificateVerify = errors.New("certificate verification failed")
+
+// ErrCertificateRequired is wrapped when the server required a client
+// certificate and the client did not send one.
+var ErrCertificateRequired = errors.New("client certificate required")
+
+// ErrHandshakeFailure is wrapped when a handshake failed for a reason none of
+// the more specific errors describe.
+var ErrHandshakeFailure = errors.New("handshake failed")
+
+// ErrHandshakeRejected is wrapped when the server aborts a handshake the
+// client already considered complete, typically because it rejected the
+// client certificate.
+var ErrHandshakeRejected = errors.New("server rejected the handshake")
+
+// ErrNoALPN is returned by NegotiatedALPN when the client offered application
+// protocols but the server did not select any of them. It is also wrapped
+// when the server aborted the handshake because none of them overlapped with
+// its own.
+var ErrNoALPN = errors.New("no ALPN protocol negotiated")
+
+// handshakeFailureMarkers map lower-case fragments of s_client and s_server
+// output to the failure they indicate.
+var handshakeFailureMarkers = []struct {
+	marker string
+	err    error
+}{
+	{"hostname mismatch", ErrHostnameMismatch},
+	{"no suitable key share", ErrNoSharedGroup},
+	{"no shared groups", ErrNoSharedGroup},
+	{"no shared cipher", ErrNoSharedCipher},
+	{"peer did not return a certificate", ErrCertificateRequired},
+	{"alert certificate required", ErrCertificateRequired},
+	{"certificate verify failed", ErrCertificateVerify},
+	{"alert unknown ca", ErrCertificateVerify},
+	{"alert bad certificate", ErrCertificateVerify},
+	{"no application protocol", ErrNoALPN},
+}
+
+// CheckHandshakeOutput inspects s_client or s_server output and returns an error
+// describing a failed handshake, or nil if no failure was reported. The error
+// wraps one of ErrHostnameMismatch, ErrNoSharedGroup, ErrNoSharedCipher,
+// ErrCertificateRequired, ErrCertificateVerify, ErrNoALPN or, if the cause is
+// not recognised, ErrHandshakeFailure, and includes the output line it is
+// based on. A peer certificate that failed verification is reported as
+// ErrCertificateVerify together with a *VerifyError even if the handshake
+// went on, as s_client does unless ClientOptions asks for verification.
+func CheckHandshakeOutput(output string) error {
+	lines := strings.Split(output, "\n")
+	var depthLine string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		for _, m := range handshakeFailureMarkers {
+			if strings.Contains(lower, m.marker) {
+				return fmt.Errorf("%w: %s", m.err, line)
+			}
+		}
+		if strings.HasPrefix(line, "depth=") {
+			depthLine = line
+		}
+		if verifyErr := parseHandshakeVerifyError(line, depthLine); verifyErr != nil {
+			return fmt.Errorf("%w: %w", ErrCertificateVerify, verifyErr)
+		}
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "handshake failure") ||
+			strings.HasPrefix(line, "SSL_connect:error in") || strings.HasPrefix(line, "SSL_accept:error in") {
+			return fmt.Errorf("%w: %s", ErrHandshakeFailure, line)
+		}
+	}
+	return nil
+}
+
+// parseHandshakeVerifyError parses a "verify error:num=N:reason" line of
+// s_client or s_server output, taking the depth and subject of the failing
+// certificate from the "depth=D subject" line printed before it. It returns
+// nil for any other line.
+func parseHandshakeVerifyError(line, depthLine string) *VerifyError {
+	rest, ok := strings.CutPrefix(line, "verify error:num=")
+	if !ok {
+		return nil
+	}
+	codeText, reason, _ := strings.Cut(rest, ":")
+	code, err := strconv.Atoi(codeText)
+	if err != nil {
+		return nil
+	}
+	verifyErr := &VerifyError{Code: code, Reason: reason}
+	// depth=0 CN = localhost
+	depthText, subject, _ := strings.Cut(strings.TrimPrefix(depthLine, "depth="), " ")
+	if depth, err := strconv.Atoi(depthText); err == nil {
+		verifyErr.Depth, verifyErr.Subject = depth, subject
+	}
+	return verifyErr
+}
+
+// WaitHandshake reads s_client output from stdout, as returned by
+// StartClient, until the handshake completes or fails, so that failures
+// surface as soon as they happen rather than whenever the pipe is read. On
+// success it returns a reader that replays the consumed output. On failure
+// the client exits, and the error from CheckHandshakeOutput is returned for
+// its output. An error is also returned if timeout elapses first.
+func WaitHandshake(stdout io.ReadCloser, timeout time.Duration) (io.ReadCloser, error) {
+	type waitResult struct {
+		stdout io.ReadCloser
+		err    error
+	}
+	done := make(chan waitResult, 1)
+	go func() {
+		reader := bufio.NewReader(stdout)
+		var consumed strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			consumed.WriteString(line)
+			line = strings.TrimSpace(line)
+			if isHandshakeComplete(line) {
+				replay := io.MultiReader(strings.NewReader(consumed.String()), reader)
+				done <- waitResult{stdout: struct {
+					io.Reader
+					io.Closer
+				}{replay, stdout}}
+				return
+			}
+			if err != nil || strings.HasPrefix(line, "SSL_connect:error in") {
+				// The error queue follows, until s_client exits
+				rest, _ := io.ReadAll(reader)
+				consumed.Write(rest)
+				output := consumed.String()
+				if checkErr := CheckHandshakeOutput(output); checkErr != nil {
+					done <- waitResult{err: checkErr}
+				} else {
+					done <- waitResult{err: fmt.Errorf("%w: s_client exited before completing the handshake\n%s", ErrHandshakeFailure, output)}
+				}
+				return
+			}
+		}
+	}()
+
+	select {
+	case res := <-done:
+		return res.stdout, res.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("handshake did not complete within %s: %w", timeout, context.DeadlineExceeded)
+	}
+}
+
+// isHandshakeComplete reports whether an s_client output line announces a
+// completed handshake, in either the default or the -brief format.
+func isHandshakeComplete(line string) bool {
+	if line == "CONNECTION ESTABLISHED" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(line, "New, ")
+	if !ok {
+		rest, ok = strings.CutPrefix(line, "Reused, ")
+	}
+	return ok && !strings.HasSuffix(rest, "Cipher is (NONE)")
+}
+
+// NegotiatedALPN returns the application protocol reported in s_client output.
+// It returns ErrNoALPN if the server selected none of the offered protocols.
+func NegotiatedALPN(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if protocol, ok := strings.CutPrefix(line, "ALPN protocol:"); ok {
+			return strings.TrimSpace(protocol), nil
+		}
+		if line == "No ALPN negotiated" {
+			return "", ErrNoALPN
+		}
+	}
+	return "", fmt.Errorf("%w: no ALPN status in output", ErrNoALPN)
+}
+
+// HandshakeInfo describes a completed TLS handshake as reported by s_client.
+type HandshakeInfo struct {
+	// Protocol is the negotiated protocol version, e.g. "TLSv1.3".
+	Protocol string
+	// Cipher is the negotiated cipher suite, e.g. "TLS_AES_256_GCM_SHA384".
+	Cipher string
+	// Group is the negotiated key exchange group, e.g. "mlkem768", taken
+	// from the "Negotiated TLS1.3 group" line that OpenSSL 3.2 and later
+	// print. Older versions only print the server's temporary key, which
+	// names classical groups such as X25519 but is missing for KEM groups,
+	// so Group is empty when a PQ group was negotiated with such a client.
+	Group string
+	// PeerSubject is the subject of the server certificate, e.g. "CN = localhost".
+	PeerSubject string
+	// ALPN is the negotiated application protocol, if any.
+	ALPN string
+	// OCSPResponseStatus is the status of the stapled OCSP response, e.g.
+	// "successful" or "tryLater", if one was sent.
+	OCSPResponseStatus string
+	// OCSP is the certificate status from the stapled OCSP response. It is
+	// only set when the client was started with ClientOptions.RequestOCSP.
+	OCSP OCSPStatus
+	// Reused reports whether a previous session was resumed instead of doing
+	// a full handshake. The -brief output format does not tell, so it is
+	// always false for it.
+	Reused bool
+}
+
+// OCSPStatus is the certificate status reported by a stapled OCSP response.
+type OCSPStatus string
+
+const (
+	// OCSPNoResponse means the client asked for a stapled response but the
+	// server did not send one.
+	OCSPNoResponse OCSPStatus = "no response"
+	OCSPGood       OCSPStatus = "good"
+	OCSPRevoked    OCSPStatus = "revoked"
+	OCSPUnknown    OCSPStatus = "unknown"
+)
+
+// ParseHandshake reads s_client output from r until EOF and returns the
+// negotiated handshake parameters. Both the default and the -brief output
+// formats are understood. If the output reports a failed handshake, the
+// error from CheckHandshakeOutput is returned.
+func ParseHandshake(r io.Reader) (HandshakeInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return HandshakeInfo{}, fmt.Errorf("failed to read handshake output: %w", err)
+	}
+	output := string(data)
+	if err := CheckHandshakeOutput(output); err != nil {
+		return HandshakeInfo{}, err
+	}
+
+	var info HandshakeInfo
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "New, "); ok {
+			// New, TLSv1.3, Cipher is TLS_AES_256_GCM_SHA384
+			if protocol, cipher, ok := strings.Cut(rest, ", Cipher is "); ok && cipher != "(NONE)" {
+				info.Protocol, info.Cipher = protocol, cipher
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "Reused, "); ok {
+			// Reused, TLSv1.3, Cipher is TLS_AES_256_GCM_SHA384
+			if protocol, cipher, ok := strings.Cut(rest, ", Cipher is "); ok {
+				info.Protocol, info.Cipher, info.Reused = protocol, cipher, true
+			}
+			continue
+		}
+		if subject, ok := strings.CutPrefix(line, "subject="); ok {
+			if info.PeerSubject == "" {
+				info.PeerSubject = subject
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "Protocol" || key == "Protocol version":
+			if info.Protocol == "" {
+				info.Protocol = value
+			}
+		case key == "Cipher" || key == "Ciphersuite":
+			if info.Cipher == "" {
+				info.Cipher = value
+			}
+		case key == "Negotiated TLS1.3 group":
+			// Takes precedence over the temporary key wherever it appears
+			info.Group = value
+		case key == "Server Temp Key":
+			// Server Temp Key: X25519, 253 bits
+			// Server Temp Key: ECDH, secp384r1, 384 bits
+			if info.Group == "" {
+				info.Group = tempKeyGroup(value)
+			}
+		case key == "Peer certificate":
+			info.PeerSubject = value
+		case key == "ALPN protocol":
+			info.ALPN = value
+		case key == "OCSP response" && value == "no response sent":
+			info.OCSP = OCSPNoResponse
+		case key == "OCSP Response Status":
+			// OCSP Response Status: successful (0x0)
+			info.OCSPResponseStatus, _, _ = strings.Cut(value, " ")
+		case key == "Cert Status" && info.OCSP == "":
+			info.OCSP = OCSPStatus(value)
+		}
+	}
+
+	if info.Protocol == "" || info.Cipher == "" {
+		return info, errors.New("handshake did not complete: no protocol or cipher negotiated")
+	}
+	return info, nil
+}
+
+// tempKeyGroup extracts the group name from a "Server Temp Key" value.
+func tempKeyGroup(value string) string {
+	fields := strings.Split(value, ", ")
+	if len(fields) > 1 && strings.HasSuffix(fields[len(fields)-1], " bits") {
+		fields = fields[:len(fields)-1]
+	}
+	return fields[len(fields)-1]
+}
+
+// Handshake connects to address, completes a single TLS handshake with
+// s_client and returns the negotiated parameters. The client process
+// is always torn down before Handshake returns. A handshake that does not
+// finish within Config.HandshakeTimeout fails with context.DeadlineExceeded.
+func Handshake(address string, opts ClientOptions) (HandshakeInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout())
+	defer cancel()
+	return HandshakeContext(ctx, address, opts)
+}
+
+// HandshakeContext is like Handshake but kills the client when ctx is done
+// instead of applying Config.HandshakeTimeout.
+func HandshakeContext(ctx context.Context, address string, opts ClientOptions) (HandshakeInfo, error) {
+	delay := connectRetryBackoff(opts)
+	for attempt := 0; ; attempt++ {
+		info, err := handshakeOnce(ctx, address, opts, "")
+		if err == nil || attempt >= opts.ConnectRetries || !errors.Is(err, ErrConnectionRefused) {
+			return info, err
+		}
+		logf("Connection to %s refused, retrying in %s", address, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return HandshakeInfo{}, fmt.Errorf("handshake with %s: %w", address, ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// handshakeOnce makes a single attempt of HandshakeContext. If sessionFile
+// is set, the session saved in it is offered for resumption.
+func handshakeOnce(ctx context.Context, address string, opts ClientOptions, sessionFile string) (HandshakeInfo, error) {
+	args := clientArgs(address, opts)
+	if sessionFile != "" {
+		args = append(args, "-sess_in", sessionFile)
+	}
+	// The -brief summary omits the OCSP response, whether the session was
+	// reused and the negotiated ALPN protocol, so keep the full output when
+	// any of them matters
+	if !opts.RequestOCSP && sessionFile == "" && len(opts.ALPN) == 0 {
+		args = append(args, "-brief")
+	}
+	cmd := opensslCommandContext(ctx, args...)
+	// With stdin at EOF, s_client closes the connection right after the
+	// handshake. The -brief summary is written to stderr.
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := dryRunError(cmd); err != nil {
+		return HandshakeInfo{}, err
+	}
+	logf("Running %s", strings.Join(cmd.Args, " "))
+	runErr := cmd.Run()
+	if ctxErr := ctx.Err(); runErr != nil && ctxErr != nil {
+		return HandshakeInfo{}, fmt.Errorf("handshake with %s: %w", address, ctxErr)
+	}
+
+	info, err := ParseHandshake(bytes.NewReader(output.Bytes()))
+	if err == nil {
+		return info, nil
+	}
+	// Keep classified handshake failures, otherwise report why openssl failed
+	if runErr != nil && CheckHandshakeOutput(output.String()) == nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			err = &OpenSSLError{
+				ExitCode: exitErr.ExitCode(),
+				Stderr:   output.String(),
+				Args:     cmd.Args[1:],
+			}
+		} else {
+			err = wrapNotFound(runErr)
+		}
+		if isConnectionRefused(output.String()) {
+			err = fmt.Errorf("%w: %w", ErrConnectionRefused, err)
+		}
+	}
+	return HandshakeInfo{}, fmt.Errorf("handshake with %s: %w", address, err)
+}
+
+// RunMTLSHandshake starts s_server on a free loopback port with serverOpts,
+// performs one handshake against it with clientOpts and stops the server
+// again. Unless serverOpts says otherwise, the server requires a client
+// certificate, so clientOpts should carry one. Likewise, clientOpts.CAFile or
+// CAPath should hold the server's root, as a server certificate the client
+// cannot verify fails the handshake with ErrCertificateVerify. Any WithPort
+// option is overridden, which makes concurrent calls safe.
+//
+// In TLS 1.3 the client considers the handshake complete before the server
+// has checked its certificate, so the server's verdict is awaited as well: if
+// the server aborts, the error wraps ErrHandshakeRejected. When the client
+// cannot tell why a handshake failed, the server output is checked too, e.g.
+// for ErrNoSharedGroup.
+func RunMTLSHandshake(serverOpts []ServerOption, clientOpts ClientOptions) (HandshakeInfo, error) {
+	opts := append(append([]ServerOption(nil), serverOpts...), WithPort(0))
+	cmd, stdin, stdout, port, err := StartServerWithOptions(opts...)
+	if err != nil {
+		return HandshakeInfo{}, err
+	}
+	defer stdin.Close()
+
+	// Collect the server output while the handshake runs, noting whether the
+	// server finished or aborted its side; Wait closes the pipe once the
+	// server is stopped
+	accepted := make(chan bool, 1)
+	serverOutput := make(chan string, 1)
+	go func() {
+		var output strings.Builder
+		decided := false
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.WriteString(line + "\n")
+			if decided {
+				continue
+			}
+			switch {
+			case line == "SSL_accept:SSLv3/TLS read finished":
+				accepted <- true
+				decided = true
+			case strings.HasPrefix(line, "SSL_accept:error in"):
+				accepted <- false
+				decided = true
+			}
+		}
+		close(accepted)
+		serverOutput <- output.String()
+	}()
+
+	info, err := Handshake(net.JoinHostPort("localhost", strconv.Itoa(port)), clientOpts)
+	if err == nil {
+		select {
+		case ok := <-accepted:
+			if !ok {
+				err = ErrHandshakeRejected
+			}
+		case <-time.After(handshakeTimeout()):
+			err = fmt.Errorf("%w: server did not complete the handshake", context.DeadlineExceeded)
+		}
+	}
+	stopErr := StopServer(cmd)
+	output := <-serverOutput
+	if err != nil {
+		// The server knows more about most failures, but not about the
+		// client rejecting the server certificate
+		serverErr := CheckHandshakeOutput(output)
+		if serverErr != nil && !errors.Is(err, ErrHostnameMismatch) && !errors.Is(err, ErrCertificateVerify) {
+			err = fmt.Errorf("%w: %w", serverErr, err)
+		} else if errors.Is(err, ErrHandshakeRejected) {
+			err = fmt.Errorf("%w\n%s", err, serverErrorLines(output))
+		}
+		return HandshakeInfo{}, err
+	}
+	if stopErr != nil {
+		return info, fmt.Errorf("failed to stop server: %w", stopErr)
+	}
+	return info, nil
+}
+
+// serverErrorLines returns the openssl error queue lines of s_server output,
+// e.g. "...:peer did not return a certificate:...".
+func serverErrorLines(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, ":error:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,350 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestPKI creates a TestPKI with EC keys that is removed when the test
+// ends.
+func newTestPKI(t *testing.T) *TestPKI {
+	t.Helper()
+	pki, err := NewTestPKI("EC")
+	if err != nil {
+		t.Fatalf("NewTestPKI: %v", err)
+	}
+	t.Cleanup(pki.Cleanup)
+	return pki
+}
+
+// newTestServer starts s_server on a free loopback port with the server
+// certificate of pki, not asking for client certificates unless opts say
+// otherwise, and returns its address. The server is stopped when the test
+// ends.
+func newTestServer(t *testing.T, pki *TestPKI, opts ...ServerOption) string {
+	t.Helper()
+	opts = append([]ServerOption{
+		WithCert(pki.ServerCert),
+		WithKey(pki.ServerKey),
+		WithClientAuth(ClientAuthNone),
+	}, append(opts, WithPort(0))...)
+	cmd, stdin, stdout, port, err := StartServerWithOptions(opts...)
+	if err != nil {
+		t.Fatalf("StartServerWithOptions: %v", err)
+	}
+	go io.Copy(io.Discard, stdout)
+	t.Cleanup(func() {
+		stdin.Close()
+		StopServer(cmd)
+	})
+	return net.JoinHostPort("localhost", strconv.Itoa(port))
+}
+
+func TestParseHandshake(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   HandshakeInfo
+	}{
+		{
+			name: "default",
+			output: `CONNECTED(00000003)
+depth=0 CN = localhost
+verify return:1
+---
+Certificate chain
+ 0 s:CN = localhost
+   i:CN = Test Root CA
+---
+Server certificate
+subject=CN = localhost
+issuer=CN = Test Root CA
+---
+No client certificate CA names sent
+Peer signing digest: SHA256
+Peer signature type: ECDSA
+Server Temp Key: X25519, 253 bits
+Negotiated TLS1.3 group: X25519
+---
+SSL handshake has read 758 bytes and written 377 bytes
+Verification: OK
+---
+New, TLSv1.3, Cipher is TLS_AES_256_GCM_SHA384
+Server public key is 256 bit
+Secure Renegotiation IS NOT supported
+Compression: NONE
+Expansion: NONE
+ALPN protocol: h2
+Early data was not sent
+Verify return code: 0 (ok)
+---
+SSL-Session:
+    Protocol  : TLSv1.3
+    Cipher    : TLS_AES_256_GCM_SHA384
+    Verify return code: 0 (ok)
+---
+DONE
+`,
+			want: HandshakeInfo{
+				Protocol:    "TLSv1.3",
+				Cipher:      "TLS_AES_256_GCM_SHA384",
+				Group:       "X25519",
+				PeerSubject: "CN = localhost",
+				ALPN:        "h2",
+			},
+		},
+		{
+			name: "brief",
+			output: `CONNECTION ESTABLISHED
+Protocol version: TLSv1.3
+Ciphersuite: TLS_AES_128_GCM_SHA256
+Peer certificate: CN = localhost
+Hash used: SHA256
+Signature type: ECDSA
+Verification: OK
+Negotiated TLS1.3 group: secp384r1
+DONE
+`,
+			want: HandshakeInfo{
+				Protocol:    "TLSv1.3",
+				Cipher:      "TLS_AES_128_GCM_SHA256",
+				Group:       "secp384r1",
+				PeerSubject: "CN = localhost",
+			},
+		},
+		{
+			name: "hybrid group",
+			output: `CONNECTION ESTABLISHED
+Protocol version: TLSv1.3
+Ciphersuite: TLS_AES_256_GCM_SHA384
+Peer certificate: CN = pq-server
+Hash used: UNDEF
+Signature type: mldsa65
+Verification: OK
+Negotiated TLS1.3 group: X25519MLKEM768
+DONE
+`,
+			want: HandshakeInfo{
+				Protocol:    "TLSv1.3",
+				Cipher:      "TLS_AES_256_GCM_SHA384",
+				Group:       "X25519MLKEM768",
+				PeerSubject: "CN = pq-server",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHandshake(strings.NewReader(tt.output))
+			if err != nil {
+				t.Fatalf("ParseHandshake: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHandshake = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHandshakeIncomplete(t *testing.T) {
+	output := `CONNECTED(00000003)
+---
+no peer certificate available
+---
+New, (NONE), Cipher is (NONE)
+`
+	if _, err := ParseHandshake(strings.NewReader(output)); err == nil {
+		t.Error("ParseHandshake succeeded for a handshake that did not complete")
+	}
+}
+
+func TestHandshakeALPN(t *testing.T) {
+	pki := newTestPKI(t)
+	address := newTestServer(t, pki, WithALPN("h2", "http/1.1"))
+
+	info, err := Handshake(address, ClientOptions{CAFile: pki.RootCert, ALPN: []string{"http/1.1"}})
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if info.ALPN != "http/1.1" {
+		t.Errorf("ALPN = %q, want %q", info.ALPN, "http/1.1")
+	}
+}
+
+func TestHandshakeALPNMismatch(t *testing.T) {
+	pki := newTestPKI(t)
+	address := newTestServer(t, pki, WithALPN("h2"))
+
+	_, err := Handshake(address, ClientOptions{CAFile: pki.RootCert, ALPN: []string{"http/1.1"}})
+	if !errors.Is(err, ErrNoALPN) {
+		t.Errorf("Handshake error = %v, want ErrNoALPN", err)
+	}
+}
+
+func TestHandshakeUntrustedServer(t *testing.T) {
+	pki := newTestPKI(t)
+	address := newTestServer(t, pki)
+	other := newTestPKI(t)
+
+	_, err := Handshake(address, ClientOptions{CAFile: other.RootCert})
+	if !errors.Is(err, ErrCertificateVerify) {
+		t.Errorf("Handshake error = %v, want ErrCertificateVerify", err)
+	}
+}
+
+func TestCheckHandshakeOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr error
+		// wantVerify is the *VerifyError expected in the chain, if any
+		wantVerify *VerifyError
+	}{
+		{
+			name: "verified",
+			output: `depth=1 CN = Test Root CA
+verify return:1
+depth=0 CN = localhost
+verify return:1
+CONNECTION ESTABLISHED
+Protocol version: TLSv1.3
+Ciphersuite: TLS_AES_256_GCM_SHA384
+Verification: OK
+DONE
+`,
+		},
+		{
+			name: "verify error without verify_return_error",
+			output: `depth=0 CN = localhost
+verify error:num=18:self-signed certificate
+CONNECTION ESTABLISHED
+Protocol version: TLSv1.3
+Ciphersuite: TLS_AES_256_GCM_SHA384
+Peer certificate: CN = localhost
+Verification error: self-signed certificate
+DONE
+`,
+			wantErr:    ErrCertificateVerify,
+			wantVerify: &VerifyError{Subject: "CN = localhost", Code: 18, Reason: "self-signed certificate"},
+		},
+		{
+			name: "verify error with verify_return_error",
+			output: `SSL_connect:TLSv1.3 read encrypted extensions
+depth=0 CN = localhost
+verify error:num=20:unable to get local issuer certificate
+SSL3 alert write:fatal:unknown CA
+SSL_connect:error in error
+40F7DFF8787F0000:error:0A000086:SSL routines:tls_post_process_server_certificate:certificate verify failed:ssl/statem/statem_clnt.c:1889:
+`,
+			wantErr:    ErrCertificateVerify,
+			wantVerify: &VerifyError{Subject: "CN = localhost", Code: 20, Reason: "unable to get local issuer certificate"},
+		},
+		{
+			name: "hostname mismatch",
+			output: `depth=0 CN = localhost
+verify error:num=62:hostname mismatch
+SSL3 alert write:fatal:bad certificate
+SSL_connect:error in error
+`,
+			wantErr: ErrHostnameMismatch,
+		},
+		{
+			name: "no application protocol",
+			output: `SSL_connect:SSLv3/TLS write client hello
+SSL3 alert read:fatal:no application protocol
+SSL_connect:error in error
+4057286E2B7F0000:error:0A000460:SSL routines:ssl3_read_bytes:tlsv1 alert no application protocol:ssl/record/rec_layer_s3.c:1605:SSL alert number 120
+`,
+			wantErr: ErrNoALPN,
+		},
+		{
+			name: "unrecognised failure",
+			output: `SSL_connect:SSLv3/TLS write client hello
+SSL_connect:error in error
+`,
+			wantErr: ErrHandshakeFailure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHandshakeOutput(tt.output)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("CheckHandshakeOutput = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckHandshakeOutput = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantVerify == nil {
+				return
+			}
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("CheckHandshakeOutput = %v, want a *VerifyError", err)
+			}
+			if *verifyErr != *tt.wantVerify {
+				t.Errorf("VerifyError = %+v, want %+v", *verifyErr, *tt.wantVerify)
+			}
+		})
+	}
+}
+
+func TestHandshakeUnverifiedWithoutCA(t *testing.T) {
+	pki := newTestPKI(t)
+	address := newTestServer(t, pki)
+
+	// s_client completes the handshake, but the server was never verified
+	_, err := Handshake(address, ClientOptions{})
+	var verifyErr *VerifyError
+	if !errors.Is(err, ErrCertificateVerify) || !errors.As(err, &verifyErr) {
+		t.Errorf("Handshake error = %v, want ErrCertificateVerify with a *VerifyError", err)
+	}
+}
+
+func TestRunMTLSHandshake(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	clientOpts := ClientOptions{
+		CertFile: pki.ClientCert,
+		KeyFile:  pki.ClientKey,
+		CAFile:   pki.RootCert,
+	}
+
+	tests := []struct {
+		name       string
+		serverOpts []ServerOption
+		wantErr    error
+	}{
+		{
+			name:       "mutual",
+			serverOpts: []ServerOption{WithCert(pki.ServerCert), WithKey(pki.ServerKey), WithCAFile(pki.RootCert)},
+		},
+		{
+			name:       "server from another root",
+			serverOpts: []ServerOption{WithCert(other.ServerCert), WithKey(other.ServerKey), WithCAFile(pki.RootCert)},
+			wantErr:    ErrCertificateVerify,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := RunMTLSHandshake(tt.serverOpts, clientOpts)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("RunMTLSHandshake: %v", err)
+				}
+				if info.PeerSubject != "CN = localhost" {
+					t.Errorf("PeerSubject = %q, want %q", info.PeerSubject, "CN = localhost")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RunMTLSHandshake error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package oqsopenssl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+// opensslTimeLayout is the layout of dates printed by openssl x509, e.g.
+// "Oct 15 06:20:18 2026 GMT".
+const opensslTimeLayout = "Jan _2 15:04:05 2006 MST"
+
+// CertInfo holds the main fields of a certificate.
+type CertInfo struct {
+	// Subject and Issuer are distinguished names, e.g. "CN = server".
+	Subject string
+	Issuer  string
+
+	// NotBefore and NotAfter bound the validity period.
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// Serial is the serial number in upper-case hex.
+	Serial string
+
+	// SANs are the subjectAltName entries.
+	SANs []SAN
+
+	// SPIFFEID is the first URI SAN with the spiffe scheme, if any.
+	SPIFFEID string
+}
+
+// ParseCertificate reads the subject, issuer, validity, serial and SANs of
+// the certificate in certFile.
+func ParseCertificate(certFile string) (*CertInfo, error) {
+	cmd := opensslCommand("x509", "-in", certFile, "-noout", "-subject", "-issuer", "-dates", "-serial", "-ext", "subjectAltName")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to parse certificate")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CertInfo{}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "X509v3 Subject Alternative Name:") {
+			if i+1 < len(lines) {
+				info.SANs = parseSANList(lines[i+1])
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "subject":
+			info.Subject = value
+		case "issuer":
+			info.Issuer = value
+		case "serial":
+			info.Serial = value
+		case "notBefore", "notAfter":
+			t, err := time.Parse(opensslTimeLayout, value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s %q: %w", key, value, err)
+			}
+			if key == "notBefore" {
+				info.NotBefore = t.UTC()
+			} else {
+				info.NotAfter = t.UTC()
+			}
+		}
+	}
+
+	info.SPIFFEID = firstSPIFFEID(info.SANs)
+	return info, nil
+}
+
+// GetSANs returns the subjectAltName entries of the certificate in certFile,
+// including its SPIFFE ID URI, in certificate order. A certificate without
+// SANs gives an empty slice.
+func GetSANs(certFile string) ([]SAN, error) {
+	info, err := ParseCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.SANs == nil {
+		return []SAN{}, nil
+	}
+	return info.SANs, nil
+}
+
+// CertificateText returns openssl's human-readable dump of the certificate in
+// certFile, as printed by x509 -text. Unlike ParseCertificate it does no
+// parsing, so it also works for certificates with unusual fields.
+func CertificateText(certFile string) (string, error) {
+	cmd := opensslCommand("x509", "-in", certFile, "-noout", "-text")
+	return runCommandOutput(context.Background(), cmd, "Failed to print certificate")
+}
+
+// firstSPIFFEID returns the first URI SAN with the spiffe scheme, if any.
+func firstSPIFFEID(sans []SAN) string {
+	for _, san := range sans {
+		if san.Type == SANURI && strings.HasPrefix(san.Value, "spiffe://") {
+			return san.Value
+		}
+	}
+	return ""
+}
+
+// parseSANList parses a subjectAltName as printed by openssl, e.g.
+// "URI:spiffe://td/x, DNS:x, IP Address:10.0.0.1, email:a@b".
+func parseSANList(line string) []SAN {
+	var sans []SAN
+	for _, entry := range strings.Split(strings.TrimSpace(line), ", ") {
+		kind, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "DNS":
+			sans = append(sans, SAN{Type: SANDNS, Value: value})
+		case "IP Address":
+			// openssl spells out IPv6 addresses, e.g. 0:0:0:0:0:0:0:1
+			if ip := net.ParseIP(value); ip != nil {
+				value = ip.String()
+			}
+			sans = append(sans, SAN{Type: SANIP, Value: value})
+		case "URI":
+			sans = append(sans, SAN{Type: SANURI, Value: value})
+		case "email":
+			sans = append(sans, SAN{Type: SANEmail, Value: value})
+		}
+	}
+	return sans
+}
+
+// Fingerprint returns the fingerprint of the certificate in certFile as
+// lower-case hex without separators. digest may be any digest the openssl
+// build provides, e.g. DigestSHA1 or DigestSHA512; if empty, DigestSHA256 is
+// used.
+func Fingerprint(certFile string, digest DigestAlgorithm) (string, error) {
+	if digest == "" {
+		digest = DigestSHA256
+	}
+	if err := digest.Validate(); err != nil {
+		return "", err
+	}
+
+	cmd := opensslCommand("x509", "-in", certFile, "-noout", "-fingerprint", digest.flag())
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to compute certificate fingerprint")
+	if err != nil {
+		return "", err
+	}
+
+	// SHA256 Fingerprint=AB:CD:...
+	_, value, ok := strings.Cut(strings.TrimSpace(output), "=")
+	if !ok {
+		return "", fmt.Errorf("unexpected fingerprint output %q", strings.TrimSpace(output))
+	}
+	return strings.ToLower(strings.ReplaceAll(value, ":", "")), nil
+}
+
+// NotAfter returns the expiry time of the certificate in certFile.
+func NotAfter(certFile string) (time.Time, error) {
+	cmd := opensslCommand("x509", "-in", certFile, "-noout", "-enddate")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to read certificate expiry")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// notAfter=Oct 16 06:20:18 2026 GMT
+	value, ok := strings.CutPrefix(strings.TrimSpace(output), "notAfter=")
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected enddate output %q", strings.TrimSpace(output))
+	}
+	notAfter, err := time.Parse(opensslTimeLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse notAfter %q: %w", value, err)
+	}
+	return notAfter.UTC(), nil
+}
+
+// DaysUntilExpiry returns the number of whole days until the certificate in
+// certFile expires. It is negative once the certificate has expired.
+func DaysUntilExpiry(certFile string) (int, error) {
+	notAfter, err := NotAfter(certFile)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Floor(time.Until(notAfter).Hours() / 24)), nil
+}
+
+// IsExpired reports whether the certificate in certFile has expired.
+func IsExpired(certFile string) (bool, error) {
+	notAfter, err := NotAfter(certFile)
+	if err != nil {
+		return false, err
+	}
+	return time.Now().After(notAfter), nil
+}
+
+// KeyMatchesCertificate reports whether the private key in keyFile belongs to
+// the certificate in certFile. It compares the public key derived from the
+// private key with the one in the certificate, which works for RSA, EC and
+// PQ keys alike.
+func KeyMatchesCertificate(keyFile, certFile string) (bool, error) {
+	cmd := opensslCommand("x509", "-in", certFile, "-noout", "-pubkey")
+	certPublicKey, err := runCommandOutput(context.Background(), cmd, "Failed to read certificate public key")
+	if err != nil {
+		return false, err
+	}
+
+	cmd = opensslCommand("pkey", "-in", keyFile, "-pubout")
+	keyPublicKey, err := runCommandOutput(context.Background(), cmd, "Failed to derive public key")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(certPublicKey) == strings.TrimSpace(keyPublicKey), nil
+}
+
+// CertificatesEqual reports whether the certificates in the files a and b are
+// the same certificate. It compares their DER encodings, so PEM and DER files
+// and differences in line endings or surrounding text do not matter.
+func CertificatesEqual(a, b string) (bool, error) {
+	derA, err := certificateDER(a)
+	if err != nil {
+		return false, err
+	}
+	derB, err := certificateDER(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(derA, derB), nil
+}
+
+// certificateDER returns the DER encoding of the certificate in certFile.
+func certificateDER(certFile string) ([]byte, error) {
+	format, err := detectFormat(certFile)
+	if err != nil {
+		return nil, err
+	}
+	cmd := opensslCommand("x509", "-in", certFile, "-inform", format, "-outform", "DER")
+	return runCommandStdout(context.Background(), cmd, "Failed to read certificate")
+}
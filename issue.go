@@ -0,0 +1,93 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// LeafOptions holds the settings for IssueLeafCertificate.
+type LeafOptions struct {
+	// Algorithm is the key algorithm for the new leaf key, e.g. "mldsa44".
+	Algorithm string
+
+	// Subject is the distinguished name passed to -subj, e.g. "/CN=server".
+	Subject string
+
+	// SPIFFEID is written to the certificate as a URI subjectAltName.
+	SPIFFEID string
+
+	// SANs are additional subjectAltName entries.
+	SANs []SAN
+
+	// ConfigFile is the openssl configuration file used to create the CSR.
+	// If empty, openssl's default configuration is used.
+	ConfigFile string
+
+	// CACertFile and CAKeyFile are the issuing CA's certificate and key.
+	CACertFile string
+	CAKeyFile  string
+
+	// CAKeyPassphrase unlocks an encrypted CA key.
+	CAKeyPassphrase io.Reader
+
+	// CAKeyPassphraseFunc is called for the CA key passphrase instead of
+	// reading CAKeyPassphrase.
+	CAKeyPassphraseFunc PassphraseFunc
+
+	// KeyFile and CertFile are where the leaf key and certificate are written.
+	KeyFile  string
+	CertFile string
+
+	// CSRFile is where the certificate request is written. If empty, a
+	// temporary file is used and removed afterwards.
+	CSRFile string
+
+	// Days is the validity period of the certificate, as in
+	// SignOptions.Days.
+	Days int
+
+	// Usage sets the keyUsage and extendedKeyUsage of the certificate.
+	// If empty, ServerUsage is used.
+	Usage Usage
+}
+
+// IssueLeafCertificate generates a new key and CSR for a leaf and signs it with
+// the CA in one step, writing the key to opts.KeyFile and the certificate to
+// opts.CertFile.
+func IssueLeafCertificate(opts LeafOptions) error {
+	if opts.KeyFile == "" || opts.CertFile == "" {
+		return errors.New("leaf key and certificate paths are required")
+	}
+
+	csrFile := opts.CSRFile
+	if csrFile == "" && dryRun() {
+		csrFile = dryRunTempPath("leaf-*.csr")
+	} else if csrFile == "" {
+		tmp, err := ioutil.TempFile("", "leaf-*.csr")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary CSR file: %w", err)
+		}
+		tmp.Close()
+		csrFile = tmp.Name()
+		defer os.Remove(csrFile)
+	}
+
+	if err := GenerateCSR(opts.Algorithm, opts.KeyFile, csrFile, opts.Subject, opts.SPIFFEID, opts.ConfigFile); err != nil {
+		return err
+	}
+	usage := opts.Usage
+	if len(usage.KeyUsage) == 0 && len(usage.ExtKeyUsage) == 0 {
+		usage = ServerUsage()
+	}
+	return SignCertificateWithOptions(csrFile, opts.CACertFile, opts.CAKeyFile, opts.CertFile, SignOptions{
+		SPIFFEID:            opts.SPIFFEID,
+		SANs:                opts.SANs,
+		Days:                opts.Days,
+		CAKeyPassphrase:     opts.CAKeyPassphrase,
+		CAKeyPassphraseFunc: opts.CAKeyPassphraseFunc,
+		Usage:               usage,
+	})
+}
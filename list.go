@@ -0,0 +1,166 @@
+package oqsopenssl
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// algorithmEntry is one algorithm reported by openssl list.
+type algorithmEntry struct {
+	Name     string
+	Provider string
+}
+
+// String returns the entry as "name@provider", or just the name if the
+// provider is unknown.
+func (a algorithmEntry) String() string {
+	if a.Provider == "" {
+		return a.Name
+	}
+	return a.Name + "@" + a.Provider
+}
+
+// ListSignatureAlgorithms returns the signature algorithms supported by the
+// openssl build, formatted as "name@provider" (e.g. "mldsa44@oqsprovider").
+func ListSignatureAlgorithms() ([]string, error) {
+	return listAlgorithmNames("-signature-algorithms", "Failed to list signature algorithms")
+}
+
+// ListKEMAlgorithms returns the KEM algorithms supported by the openssl build,
+// formatted as "name@provider" (e.g. "mlkem768@oqsprovider").
+func ListKEMAlgorithms() ([]string, error) {
+	return listAlgorithmNames("-kem-algorithms", "Failed to list KEM algorithms")
+}
+
+// ListTLSGroups returns the TLS groups, i.e. key exchange methods, that the
+// openssl build can negotiate, e.g. "X25519" or the hybrid
+// "X25519MLKEM768", including those added by providers such as the oqs
+// provider. The names are the ones WithGroups and ClientOptions.Groups take.
+// It requires OpenSSL 3.0 or later. Only OpenSSL 3.5 and later can list the
+// groups themselves; for older versions the list is made up of the
+// classical groups of the default provider and the KEM algorithms of other
+// providers, which the oqs provider also registers as groups.
+func ListTLSGroups() ([]string, error) {
+	major, minor, _, err := Version()
+	if err != nil {
+		return nil, err
+	}
+	if major < 3 || major == 3 && minor < 5 {
+		return listTLSGroupsFromKEMs()
+	}
+
+	cmd := opensslCommand("list", "-tls-groups")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to list TLS groups")
+	if err != nil {
+		return nil, err
+	}
+	return parseGroupList(output), nil
+}
+
+// classicTLSGroups are the TLS groups of the default provider in OpenSSL 3.0
+// to 3.4.
+var classicTLSGroups = []string{
+	"secp256r1", "secp384r1", "secp521r1", "X25519", "X448",
+	"ffdhe2048", "ffdhe3072", "ffdhe4096", "ffdhe6144", "ffdhe8192",
+}
+
+// listTLSGroupsFromKEMs is ListTLSGroups for OpenSSL versions without list
+// -tls-groups.
+func listTLSGroupsFromKEMs() ([]string, error) {
+	entries, err := listAlgorithms("-kem-algorithms", "Failed to list KEM algorithms")
+	if err != nil {
+		return nil, err
+	}
+	groups := append([]string{}, classicTLSGroups...)
+	for _, entry := range entries {
+		// The default provider's KEMs, such as RSA, are not TLS groups
+		if entry.Provider != "default" {
+			groups = append(groups, entry.Name)
+		}
+	}
+	return groups, nil
+}
+
+// parseGroupList parses the colon-separated group names printed by openssl
+// list -tls-groups, dropping duplicates.
+func parseGroupList(output string) []string {
+	groups := []string{}
+	seen := make(map[string]bool)
+	for _, field := range strings.FieldsFunc(output, func(r rune) bool {
+		return r == ':' || unicode.IsSpace(r)
+	}) {
+		if !seen[field] {
+			seen[field] = true
+			groups = append(groups, field)
+		}
+	}
+	return groups
+}
+
+// listAlgorithmNames runs openssl list with flag and formats the entries.
+func listAlgorithmNames(flag, errorMessage string) ([]string, error) {
+	entries, err := listAlgorithms(flag, errorMessage)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.String())
+	}
+	return names, nil
+}
+
+// listAlgorithms runs openssl list with flag and parses its output.
+func listAlgorithms(flag, errorMessage string) ([]algorithmEntry, error) {
+	cmd := opensslCommand("list", flag)
+	output, err := runCommandOutput(context.Background(), cmd, errorMessage)
+	if err != nil {
+		return nil, err
+	}
+	return parseAlgorithmList(output), nil
+}
+
+// parseAlgorithmList parses lines such as
+//
+//	{ 1.3.101.112, ED25519 } @ default
+//	mldsa44 @ oqsprovider
+//
+// into deduplicated entries. When openssl lists several aliases for one
+// algorithm, the first name that is not an OID is used.
+func parseAlgorithmList(output string) []algorithmEntry {
+	var entries []algorithmEntry
+	seen := make(map[algorithmEntry]bool)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+
+		names, provider, _ := strings.Cut(line, " @ ")
+		names = strings.Trim(names, "{} ")
+		var name string
+		for _, alias := range strings.Split(names, ",") {
+			alias = strings.TrimSpace(alias)
+			if alias != "" && !isOID(alias) {
+				name = alias
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		entry := algorithmEntry{Name: name, Provider: strings.TrimSpace(provider)}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// isOID reports whether s is a dotted numeric object identifier.
+func isOID(s string) bool {
+	return strings.Trim(s, "0123456789.") == "" && strings.Contains(s, ".")
+}
@@ -0,0 +1,26 @@
+package oqsopenssl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListTLSGroups(t *testing.T) {
+	groups, err := ListTLSGroups()
+	if err != nil {
+		t.Fatalf("ListTLSGroups: %v", err)
+	}
+	for _, want := range []string{"secp256r1", "X25519"} {
+		if !slices.Contains(groups, want) {
+			t.Errorf("ListTLSGroups = %v, missing %s", groups, want)
+		}
+	}
+}
+
+func TestParseGroupList(t *testing.T) {
+	got := parseGroupList("secp256r1:X25519:X25519MLKEM768\nX25519\n")
+	want := []string{"secp256r1", "X25519", "X25519MLKEM768"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseGroupList = %v, want %v", got, want)
+	}
+}
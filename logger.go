@@ -0,0 +1,29 @@
+package oqsopenssl
+
+// Logger receives the package's diagnostic output. *log.Logger satisfies it,
+// and structured loggers can be adapted with a small wrapper.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// nopLogger discards everything. It is used when no logger has been set.
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// SetLogger routes the package's diagnostic output to l. Passing nil restores
+// the default, which discards all output.
+func SetLogger(l Logger) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config.Logger = l
+}
+
+// logf writes a diagnostic message to the configured logger.
+func logf(format string, args ...interface{}) {
+	logger := GetConfig().Logger
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	logger.Printf(format, args...)
+}
@@ -0,0 +1,172 @@
+package oqsopenssl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigOptions describes an openssl configuration file for req, as written
+// by WriteConfig.
+type ConfigOptions struct {
+	// Subject maps distinguished name attributes to values, e.g.
+	// {"CN": "server", "O": "Example"}. Well-known attributes are written
+	// in the conventional C, ST, L, O, OU, CN order, others after them.
+	Subject map[string]string
+
+	// SPIFFEID and SANs are written as the subjectAltName extension.
+	SPIFFEID string
+	SANs     []SAN
+
+	// IsCA marks certificates made with the config as CAs.
+	IsCA bool
+
+	// Usage sets the keyUsage and extendedKeyUsage extensions.
+	Usage Usage
+
+	// Extensions holds any further extensions, e.g.
+	// {"subjectKeyIdentifier": "hash"}.
+	Extensions map[string]string
+}
+
+// dnOrder is the conventional order of distinguished name attributes.
+var dnOrder = []string{"C", "ST", "L", "O", "OU", "CN", "emailAddress"}
+
+// WriteConfig writes an openssl configuration file with a distinguished name
+// section and a v3 extensions section used for both CSRs and self-signed
+// certificates. It returns the file path and a cleanup function that removes
+// the file.
+func WriteConfig(opts ConfigOptions) (path string, cleanup func(), err error) {
+	content, err := renderConfig(opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return createTempFile("openssl-*.cnf", []byte(content))
+}
+
+// writeTempConfig writes content to a temporary configuration file and
+// returns its path and a cleanup function that removes it.
+func writeTempConfig(content string) (path string, cleanup func(), err error) {
+	return writeTempFile("openssl-*.cnf", []byte(content))
+}
+
+// writeTempFile writes data to a new temporary file named after pattern and
+// returns its path and a cleanup function that removes it. In dry-run mode
+// nothing is written, and the path is only a placeholder for the command
+// line.
+func writeTempFile(pattern string, data []byte) (path string, cleanup func(), err error) {
+	if dryRun() {
+		return dryRunTempPath(pattern), func() {}, nil
+	}
+	return createTempFile(pattern, data)
+}
+
+// dryRunTempPath returns the placeholder path of a temporary file named after
+// pattern, as reported in dry-run mode where the file is not created.
+func dryRunTempPath(pattern string) string {
+	return filepath.Join(os.TempDir(), strings.Replace(pattern, "*", "dryrun", 1))
+}
+
+// createTempFile is writeTempFile regardless of dry-run mode.
+func createTempFile(pattern string, data []byte) (path string, cleanup func(), err error) {
+	file, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup = func() { os.Remove(file.Name()) }
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	return file.Name(), cleanup, nil
+}
+
+// renderConfig returns the configuration file content for opts.
+func renderConfig(opts ConfigOptions) (string, error) {
+	var b strings.Builder
+	b.WriteString("[ req ]\n")
+	b.WriteString("prompt = no\n")
+	b.WriteString("distinguished_name = req_dn\n")
+	b.WriteString("req_extensions = v3_ext\n")
+	b.WriteString("x509_extensions = v3_ext\n")
+
+	b.WriteString("\n[ req_dn ]\n")
+	for _, attr := range subjectAttributeOrder(opts.Subject) {
+		if err := writeConfigValue(&b, attr, opts.Subject[attr]); err != nil {
+			return "", err
+		}
+	}
+
+	b.WriteString("\n[ v3_ext ]\n")
+	altName, err := subjectAltName(opts.SPIFFEID, opts.SANs)
+	if err != nil {
+		return "", err
+	}
+	if altName != "" {
+		if err := writeConfigValue(&b, "subjectAltName", altName); err != nil {
+			return "", err
+		}
+	}
+	if opts.IsCA {
+		b.WriteString("basicConstraints = critical,CA:TRUE\n")
+	}
+	for _, line := range opts.Usage.extensions() {
+		name, value, _ := strings.Cut(line, "=")
+		if err := writeConfigValue(&b, name, value); err != nil {
+			return "", err
+		}
+	}
+	names := make([]string, 0, len(opts.Extensions))
+	for name := range opts.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeConfigValue(&b, name, opts.Extensions[name]); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// subjectAttributeOrder returns the keys of subject in the order they should
+// appear in the distinguished name.
+func subjectAttributeOrder(subject map[string]string) []string {
+	var attrs []string
+	known := make(map[string]bool, len(dnOrder))
+	for _, attr := range dnOrder {
+		known[attr] = true
+		if _, ok := subject[attr]; ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	var others []string
+	for attr := range subject {
+		if !known[attr] {
+			others = append(others, attr)
+		}
+	}
+	sort.Strings(others)
+	return append(attrs, others...)
+}
+
+// writeConfigValue writes a "name = value" line, escaping characters that
+// openssl's config parser would otherwise interpret.
+func writeConfigValue(b *strings.Builder, name, value string) error {
+	if strings.ContainsAny(name+value, "\r\n") {
+		return fmt.Errorf("config entry %q contains a line break", name)
+	}
+	value = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `#`, `\#`).Replace(value)
+	fmt.Fprintf(b, "%s = %s\n", name, value)
+	return nil
+}
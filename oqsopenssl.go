@@ -1,152 +1,1711 @@
-package oqsopenssl
-
-import (
-	"fmt"
-	"os/exec"
-	"io"
-	"io/ioutil"
-	"os"
-)
-
-// GeneratePrivateKey generates a private key using a specified algorithm.
-func GeneratePrivateKey(algorithm, outputFile string) error {
-	cmd := exec.Command("openssl", "genpkey", "-algorithm", algorithm, "-out", outputFile)
-	return runCommand(cmd, "Failed to generate private key")
-}
-
-// GenerateRootCertificate creates a root CA certificate.
-func GenerateRootCertificate(keyFile, outputFile, subj, spiffeID, configFile string, days int) error {
-	cmd := exec.Command(
-		"openssl", 
-		"req", 
-		"-nodes", 
-		"-new", 
-		"-x509", 
-		"-key", keyFile, 
-		"-out", outputFile, 
-		"-days", fmt.Sprintf("%d", days), 
-		"-subj", subj, 
-		"-addext", fmt.Sprintf("subjectAltName=URI:%s", subj), 
-		// fmt.Sprintf(`-extfile <(echo 'subjectAltName=URI:%s')`, spiffeID),
-		"-config", configFile,
-	)
-	return runCommand(cmd, "Failed to generate root certificate")
-}
-
-// GenerateCSR generates a certificate signing request (CSR) for the server.
-func GenerateCSR(algorithm, keyFile, csrFile, subj, spiffeID, configFile string) error {
-	cmd := exec.Command(
-		"openssl", 
-		"req", 
-		"-nodes", 
-		"-new", 
-		"-newkey", algorithm, 
-		"-keyout", keyFile, 
-		"-out", csrFile, 
-		"-subj", subj, 
-		"-config", configFile,
-	)
-	return runCommand(cmd, "Failed to generate CSR")
-}
-
-// SignCertificate signs the server certificate with the CA certificate.
-func SignCertificate(csrFile, caCertFile, caKeyFile, spiffeID, outputFile string, days int) error {
-	// Create a temporary file to hold the extensions
-	extFile, err := ioutil.TempFile("", "extfile-*.conf")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary extension file: %w", err)
-	}
-	defer os.Remove(extFile.Name()) // Clean up the temp file after use
-
-	// Write the subjectAltName to the temporary file
-	_, err = extFile.WriteString(fmt.Sprintf("subjectAltName=URI:%s\n", spiffeID))
-	if err != nil {
-		return fmt.Errorf("failed to write to temporary extension file: %w", err)
-	}
-	if err := extFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary extension file: %w", err)
-	}
-
-	// Prepare the command to sign the certificate
-	cmd := exec.Command(
-		"openssl",
-		"x509",
-		"-req",
-		"-extfile", extFile.Name(), // Use the temporary extension file
-		"-in", csrFile,
-		"-CA", caCertFile,
-		"-CAkey", caKeyFile,
-		"-CAcreateserial",
-		"-out", outputFile,
-		"-days", fmt.Sprintf("%d", days),
-	)
-
-	// Execute the command and check for errors
-	return runCommand(cmd, "Failed to sign certificate")
-}
-
-// StartServer starts the OpenSSL server with the specified certificate and key.
-func StartServer(certFile string, keyFile string, caFile string) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
-	cmd := exec.Command("openssl", "s_server", "-accept", "4433", "-state", "-cert", certFile, "-key", keyFile, "-tls1_3", "-Verify", "1", "-CAfile", caFile, "-www")
-
-	// Create the StdoutPipe before starting the command
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Println("Error creating stdout pipe:", err)
-		return nil, nil, nil, err
-	}
-
-	// Create the StdinPipe before starting the command
-	stdinPipe, err := cmd.StdinPipe()
-	if err != nil {
-		fmt.Println("Error creating stdin pipe:", err)
-		return nil, nil, nil, err
-	}
-
-	if err := cmd.Start(); err != nil {
-		fmt.Println("Error starting OpenSSL s_server:", err)
-		return nil, nil, nil, err
-	}
-
-	// Return both the command and the stdoutPipe if needed
-	return cmd, stdinPipe, stdoutPipe, nil
-}
-
-// StartClient connects to the OpenSSL server using the specified client certificate and key.
-func StartClient(address, certFile, keyFile, caCertFile string) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
-	cmd := exec.Command("openssl", "s_client", "-connect", address, "-state", "-cert", certFile, "-key", keyFile, "-tls1_3", "-CAfile", caCertFile)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Println("Error creating stdout pipe:", err)
-		return nil, nil, nil, err
-	}
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		fmt.Println("Error creating stdin pipe:", err)
-		return nil, nil, nil, err
-	}
-
-	if err := cmd.Start(); err != nil {
-		fmt.Println("Error starting OpenSSL s_client:", err)
-		return nil, nil, nil, err
-	}
-	return cmd, stdin, stdout, nil
-}
-
-// runCommand executes an exec.Command and captures its output.
-func runCommand(cmd *exec.Cmd, errorMessage string) error {
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s\n%s", errorMessage, err, string(output))
-	}
-	fmt.Println(string(output)) // Print command output for logging
-	return nil
-}
-
-// ValidateCertificate checks if the provided certificate is valid against the specified CA certificate.
-func ValidateCertificate(certFile, caCertFile string) error {
-	cmd := exec.Command("openssl", "verify", "-CAfile", caCertFile, certFile)
-	return runCommand(cmd, "Failed to validate certificate")
-}
+package oqsopenssl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GeneratePrivateKey generates a private key using a specified algorithm.
+func GeneratePrivateKey(algorithm, outputFile string) error {
+	return GeneratePrivateKeyContext(context.Background(), algorithm, outputFile)
+}
+
+// GeneratePrivateKeyContext generates a private key like GeneratePrivateKey,
+// killing the openssl process if ctx is cancelled or its deadline passes.
+func GeneratePrivateKeyContext(ctx context.Context, algorithm, outputFile string) error {
+	return GeneratePrivateKeyWithOptions(ctx, algorithm, outputFile, KeyOptions{})
+}
+
+// KeyOptions holds optional settings for GeneratePrivateKeyWithOptions.
+type KeyOptions struct {
+	// Passphrase, if set, encrypts the generated key. It is fed to openssl on
+	// stdin so it never shows up in the process list.
+	Passphrase io.Reader
+
+	// PassphraseFunc is an alternative to Passphrase that is only called
+	// when the key is about to be encrypted. The two cannot be combined.
+	PassphraseFunc PassphraseFunc
+
+	// Cipher is the cipher used to encrypt the key, e.g. "aes256" or
+	// "chacha20". It defaults to "aes256" and requires a passphrase.
+	Cipher string
+
+	// RSABits sets the modulus size of RSA and RSA-PSS keys, e.g. 4096.
+	RSABits int
+
+	// ECCurve sets the curve of EC keys, e.g. "P-384".
+	ECCurve string
+
+	// PublicKeyFile, if set, also receives the matching public key.
+	PublicKeyFile string
+
+	// ExtraArgs are appended verbatim to the genpkey command line, e.g.
+	// {"-pkeyopt", "..."} for provider-specific parameters. They are neither
+	// validated nor escaped, so they must come from a trusted source.
+	ExtraArgs []string
+}
+
+// GeneratePrivateKeyWithOptions generates a private key using a specified
+// algorithm and the given options. An algorithm unknown to the openssl build
+// is rejected before any key is generated; see ValidateAlgorithm.
+func GeneratePrivateKeyWithOptions(ctx context.Context, algorithm, outputFile string, opts KeyOptions) error {
+	if err := validateAlgorithm(ctx, algorithm); err != nil {
+		return err
+	}
+	args, err := genpkeyArgs(algorithm, opts)
+	if err != nil {
+		return err
+	}
+	args = append(args, "-out", outputFile)
+
+	passphrase := opts.Passphrase
+	if opts.PassphraseFunc != nil {
+		r, wipe, err := opts.PassphraseFunc.reader()
+		if err != nil {
+			return err
+		}
+		defer wipe()
+		passphrase = r
+	}
+
+	// The passphrase is needed again to read the key back for the public key
+	var passphraseBytes []byte
+	if passphrase != nil && opts.PublicKeyFile != "" {
+		if passphraseBytes, err = io.ReadAll(passphrase); err != nil {
+			return fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		defer clear(passphraseBytes)
+		passphrase = bytes.NewReader(passphraseBytes)
+	}
+
+	cmd := opensslCommandContext(ctx, args...)
+	cmd.Stdin = passphrase
+	if err := runCommandContext(ctx, cmd, "Failed to generate private key"); err != nil {
+		return err
+	}
+
+	if opts.PublicKeyFile == "" {
+		return nil
+	}
+	if passphrase != nil {
+		passphrase = bytes.NewReader(passphraseBytes)
+	}
+	return extractPublicKey(ctx, outputFile, opts.PublicKeyFile, passphrase)
+}
+
+// ExtractPublicKey writes the public key of the private key in keyFile to
+// outputFile in PEM SubjectPublicKeyInfo form, which works for classical and
+// PQ algorithms alike.
+func ExtractPublicKey(keyFile, outputFile string) error {
+	return extractPublicKey(context.Background(), keyFile, outputFile, nil)
+}
+
+// extractPublicKey runs pkey -pubout, unlocking the key with passphrase if set.
+func extractPublicKey(ctx context.Context, keyFile, outputFile string, passphrase io.Reader) error {
+	args := []string{"pkey", "-in", keyFile, "-pubout", "-out", outputFile}
+	if passphrase != nil {
+		args = append(args, "-passin", "stdin")
+	}
+	cmd := opensslCommandContext(ctx, args...)
+	cmd.Stdin = passphrase
+	return classifyPassphraseError(runCommandContext(ctx, cmd, "Failed to extract public key"))
+}
+
+// GeneratePrivateKeyBytes generates a private key using a specified algorithm
+// and returns it PEM-encoded. The key is read from openssl's stdout and never
+// written to disk.
+func GeneratePrivateKeyBytes(algorithm string) ([]byte, error) {
+	if err := ValidateAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	args, err := genpkeyArgs(algorithm, KeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cmd := opensslCommand(args...)
+	return runCommandStdout(context.Background(), cmd, "Failed to generate private key")
+}
+
+// GeneratePrivateKeyTo generates a private key using a specified algorithm
+// and writes it PEM-encoded to w, without touching the disk.
+func GeneratePrivateKeyTo(w io.Writer, algorithm string) error {
+	key, err := GeneratePrivateKeyBytes(algorithm)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(key); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	return nil
+}
+
+// genpkeyArgs returns the genpkey arguments for algorithm and opts, without
+// the output file.
+func genpkeyArgs(algorithm string, opts KeyOptions) ([]string, error) {
+	args := []string{"genpkey", "-algorithm", algorithm}
+	if opts.Passphrase != nil && opts.PassphraseFunc != nil {
+		return nil, errors.New("passphrase and passphrase func cannot both be set")
+	}
+	if opts.Passphrase != nil || opts.PassphraseFunc != nil {
+		cipher := opts.Cipher
+		if cipher == "" {
+			cipher = "aes256"
+		}
+		args = append(args, "-"+strings.TrimPrefix(cipher, "-"), "-pass", "stdin")
+	} else if opts.Cipher != "" {
+		return nil, errors.New("key cipher requires a passphrase")
+	}
+	if opts.RSABits != 0 {
+		if !strings.EqualFold(algorithm, "RSA") && !strings.EqualFold(algorithm, "RSA-PSS") {
+			return nil, fmt.Errorf("RSA key size cannot be used with algorithm %q", algorithm)
+		}
+		args = append(args, "-pkeyopt", fmt.Sprintf("rsa_keygen_bits:%d", opts.RSABits))
+	}
+	if opts.ECCurve != "" {
+		if !strings.EqualFold(algorithm, "EC") {
+			return nil, fmt.Errorf("EC curve cannot be used with algorithm %q", algorithm)
+		}
+		args = append(args, "-pkeyopt", "ec_paramgen_curve:"+opts.ECCurve)
+	}
+	args = append(args, opts.ExtraArgs...)
+	return args, nil
+}
+
+// GenerateRootCertificate creates a root CA certificate. subj is in -subj
+// notation, e.g. "/CN=root"; DN.String renders it with the escaping openssl
+// expects.
+func GenerateRootCertificate(keyFile, outputFile, subj, spiffeID, configFile string, days int) error {
+	return GenerateRootCertificateWithOptions(keyFile, outputFile, RootOptions{
+		Subject:    subj,
+		SPIFFEID:   spiffeID,
+		ConfigFile: configFile,
+		Days:       days,
+	})
+}
+
+// GenerateRootCertificateWithValidity is like GenerateRootCertificate but
+// takes the validity period as a duration, rounded to the nearest day. The
+// validity must be positive.
+func GenerateRootCertificateWithValidity(keyFile, outputFile, subj, spiffeID, configFile string, validity time.Duration) error {
+	if validity <= 0 {
+		return fmt.Errorf("validity %s is not positive", validity)
+	}
+	return GenerateRootCertificateWithOptions(keyFile, outputFile, RootOptions{
+		Subject:    subj,
+		SPIFFEID:   spiffeID,
+		ConfigFile: configFile,
+		Validity:   validity,
+	})
+}
+
+// RootOptions holds the settings for GenerateRootCertificateWithOptions.
+type RootOptions struct {
+	// Subject is the distinguished name passed to -subj, e.g. "/CN=root".
+	Subject string
+
+	// DN is used as the subject when Subject is empty, rendered with
+	// DN.String. The two cannot both be set.
+	DN DN
+
+	// SPIFFEID is written to the certificate as a URI subjectAltName.
+	SPIFFEID string
+
+	// SANs are additional subjectAltName entries.
+	SANs []SAN
+
+	// ConfigFile is the openssl configuration file used by req. If empty,
+	// openssl's default configuration is used.
+	ConfigFile string
+
+	// Days is the validity period of the certificate. If zero and no other
+	// validity is set, openssl's default of 30 days is used. It must not be
+	// negative.
+	Days int
+
+	// Validity is the validity period as a duration, an alternative to Days.
+	// openssl only takes whole days, so it is rounded to the nearest day, but
+	// never below one. It cannot be combined with Days.
+	Validity time.Duration
+
+	// NotBefore and NotAfter set an explicit validity window instead of
+	// Days, e.g. to backdate a certificate. Either may be left zero. They
+	// require OpenSSL 3.4 or later and cannot be combined with Days or
+	// Validity.
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// ExtraArgs are appended verbatim to the req command line, unvalidated
+	// and unescaped.
+	ExtraArgs []string
+}
+
+// GenerateRootCertificateWithOptions creates a self-signed root CA certificate
+// for keyFile and writes it to outputFile.
+func GenerateRootCertificateWithOptions(keyFile, outputFile string, opts RootOptions) error {
+	if err := checkInputFiles(keyFile); err != nil {
+		return err
+	}
+	altName, err := subjectAltName(opts.SPIFFEID, opts.SANs)
+	if err != nil {
+		return err
+	}
+	validity, err := validityArgs(opts.Days, opts.Validity, opts.NotBefore, opts.NotAfter)
+	if err != nil {
+		return err
+	}
+	subject := opts.Subject
+	if opts.DN != (DN{}) {
+		if subject != "" {
+			return errors.New("subject and DN cannot both be set")
+		}
+		subject = opts.DN.String()
+	}
+
+	args := []string{
+		"req",
+		"-nodes",
+		"-new",
+		"-x509",
+		"-key", keyFile,
+		"-out", outputFile,
+		"-subj", subject,
+	}
+	args = append(args, validity...)
+	if altName != "" {
+		args = append(args, "-addext", "subjectAltName="+altName)
+	}
+	if opts.ConfigFile != "" {
+		args = append(args, "-config", opts.ConfigFile)
+	}
+	args = append(args, opts.ExtraArgs...)
+
+	cmd := opensslCommand(args...)
+	return runCommand(cmd, "Failed to generate root certificate")
+}
+
+// validityArgs returns the openssl flags for a validity period given in days,
+// as a duration or as an explicit notBefore/notAfter window.
+func validityArgs(days int, validity time.Duration, notBefore, notAfter time.Time) ([]string, error) {
+	if days < 0 {
+		return nil, fmt.Errorf("validity of %d days is negative", days)
+	}
+	if validity < 0 {
+		return nil, fmt.Errorf("validity %s is not positive", validity)
+	}
+	if validity > 0 {
+		if days != 0 {
+			return nil, errors.New("validity days cannot be combined with a validity duration")
+		}
+		days = max(1, int((validity+12*time.Hour)/(24*time.Hour)))
+	}
+	if notBefore.IsZero() && notAfter.IsZero() {
+		// Without -days openssl applies its default of 30 days; -days 0 would
+		// give a certificate that expires as soon as it is issued
+		if days == 0 {
+			return nil, nil
+		}
+		return []string{"-days", fmt.Sprintf("%d", days)}, nil
+	}
+	if days != 0 {
+		return nil, errors.New("validity days cannot be combined with explicit notBefore/notAfter times")
+	}
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		return nil, fmt.Errorf("notAfter %s is not after notBefore %s", notAfter, notBefore)
+	}
+
+	const layout = "20060102150405Z"
+	var args []string
+	if !notBefore.IsZero() {
+		args = append(args, "-not_before", notBefore.UTC().Format(layout))
+	}
+	if !notAfter.IsZero() {
+		args = append(args, "-not_after", notAfter.UTC().Format(layout))
+	}
+	return args, nil
+}
+
+// GenerateCSR generates a certificate signing request (CSR) for the server.
+// subj is in -subj notation, e.g. "/CN=server"; DN.String renders it with the
+// escaping openssl expects. If configFile is empty, openssl's default
+// configuration is used and the SPIFFE ID is requested as a URI
+// subjectAltName; otherwise requested extensions come from the config file.
+func GenerateCSR(algorithm, keyFile, csrFile, subj, spiffeID, configFile string) error {
+	args, err := csrArgs(csrFile, subj, spiffeID, configFile)
+	if err != nil {
+		return err
+	}
+	args = append(args, "-nodes", "-newkey", algorithm, "-keyout", keyFile)
+	cmd := opensslCommand(args...)
+	return runCommand(cmd, "Failed to generate CSR")
+}
+
+// GenerateCSRWithDN is like GenerateCSR but takes the subject as a DN, which
+// is rendered with the escaping -subj needs.
+func GenerateCSRWithDN(algorithm, keyFile, csrFile string, dn DN, spiffeID, configFile string) error {
+	return GenerateCSR(algorithm, keyFile, csrFile, dn.String(), spiffeID, configFile)
+}
+
+// GenerateCSRFromKey is like GenerateCSR but creates the request for the
+// existing unencrypted private key in keyFile instead of generating one.
+func GenerateCSRFromKey(keyFile, csrFile, subj, spiffeID, configFile string) error {
+	if err := checkInputFiles(keyFile); err != nil {
+		return err
+	}
+	args, err := csrArgs(csrFile, subj, spiffeID, configFile)
+	if err != nil {
+		return err
+	}
+	args = append(args, "-key", keyFile)
+	cmd := opensslCommand(args...)
+	return runCommand(cmd, "Failed to generate CSR")
+}
+
+// csrArgs returns the req arguments shared by GenerateCSR and
+// GenerateCSRFromKey, without the key selection.
+func csrArgs(csrFile, subj, spiffeID, configFile string) ([]string, error) {
+	args := []string{
+		"req",
+		"-new",
+		"-out", csrFile,
+		"-subj", subj,
+	}
+	if configFile != "" {
+		args = append(args, "-config", configFile)
+	} else if spiffeID != "" {
+		altName, err := subjectAltName(spiffeID, nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-addext", "subjectAltName="+altName)
+	}
+	return args, nil
+}
+
+// SignCertificate signs the server certificate with the CA certificate. If
+// days is zero, openssl's default validity of 30 days is used.
+func SignCertificate(csrFile, caCertFile, caKeyFile, spiffeID, outputFile string, days int) error {
+	return SignCertificateWithOptions(csrFile, caCertFile, caKeyFile, outputFile, SignOptions{
+		SPIFFEID: spiffeID,
+		Days:     days,
+	})
+}
+
+// SignCertificateWithValidity is like SignCertificate but takes the validity
+// period as a duration, rounded to the nearest day. The validity must be
+// positive.
+func SignCertificateWithValidity(csrFile, caCertFile, caKeyFile, spiffeID, outputFile string, validity time.Duration) error {
+	if validity <= 0 {
+		return fmt.Errorf("validity %s is not positive", validity)
+	}
+	return SignCertificateWithOptions(csrFile, caCertFile, caKeyFile, outputFile, SignOptions{
+		SPIFFEID: spiffeID,
+		Validity: validity,
+	})
+}
+
+// SignOptions holds the settings for SignCertificateWithOptions.
+type SignOptions struct {
+	// SPIFFEID is written to the certificate as a URI subjectAltName.
+	SPIFFEID string
+
+	// SANs are additional subjectAltName entries.
+	SANs []SAN
+
+	// Days is the validity period of the certificate. If zero and no other
+	// validity is set, openssl's default of 30 days is used. It must not be
+	// negative.
+	Days int
+
+	// Validity is the validity period as a duration, an alternative to Days.
+	// openssl only takes whole days, so it is rounded to the nearest day, but
+	// never below one. It cannot be combined with Days.
+	Validity time.Duration
+
+	// NotBefore and NotAfter set an explicit validity window instead of
+	// Days, e.g. to backdate a certificate. Either may be left zero. They
+	// require OpenSSL 3.4 or later and cannot be combined with Days or
+	// Validity.
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// CAKeyPassphrase unlocks an encrypted CA key. It is fed to openssl on
+	// stdin so it never shows up in the process list.
+	CAKeyPassphrase io.Reader
+
+	// CAKeyPassphraseFunc is an alternative to CAKeyPassphrase that is only
+	// called when openssl is about to unlock the CA key. The two cannot be
+	// combined.
+	CAKeyPassphraseFunc PassphraseFunc
+
+	// Digest is the message digest used to sign the certificate, e.g.
+	// DigestSHA384. If empty, openssl's default is used. Signature
+	// algorithms with a built-in digest, such as Ed25519 and the PQ schemes,
+	// ignore it.
+	Digest DigestAlgorithm
+
+	// CopyExtensions controls whether extensions requested in the CSR are
+	// carried over to the certificate. Extensions set through SignOptions,
+	// such as the SANs, always take precedence over copied ones.
+	//
+	// Copying lets whoever wrote the CSR request any extension, including
+	// subjectAltName entries for identities they do not own or
+	// basicConstraints CA:TRUE with CopyExtensionsAll. Only enable it for
+	// CSRs from trusted sources, or after inspecting them.
+	CopyExtensions CopyExtensions
+
+	// Serial sets the certificate serial number, making it deterministic.
+	Serial *big.Int
+
+	// SerialFile is the serial number file openssl reads and increments
+	// (-CAserial). It is created if missing. By default a .srl file next to
+	// the CA certificate is used, e.g. ca.srl for ca.pem. It cannot be
+	// combined with Serial.
+	SerialFile string
+
+	// IsCA issues an intermediate CA certificate that can itself sign
+	// certificates, marked with basicConstraints CA:TRUE.
+	IsCA bool
+
+	// MaxPathLen limits how many intermediate CAs may follow this one in a
+	// chain when IsCA is set. 0 allows it to sign only leaves; a negative
+	// value sets no limit.
+	MaxPathLen int
+
+	// OmitKeyIdentifiers leaves out the subjectKeyIdentifier and
+	// authorityKeyIdentifier extensions, which are otherwise added so that
+	// strict validators and clients building chains can match the
+	// certificate to its issuer.
+	OmitKeyIdentifiers bool
+
+	// Usage sets the keyUsage and extendedKeyUsage extensions. Strict TLS
+	// stacks such as Go's crypto/tls check the extended key usage, so leaf
+	// certificates should normally use ServerUsage or ClientUsage. When IsCA
+	// is set and Usage.KeyUsage is empty, keyCertSign and cRLSign are used.
+	Usage Usage
+
+	// ExtraArgs are appended verbatim to the x509 -req command line,
+	// unvalidated and unescaped.
+	ExtraArgs []string
+}
+
+// Usage lists the keyUsage and extendedKeyUsage values of a certificate using
+// openssl's names, e.g. "digitalSignature" or "serverAuth". Extended key
+// usages may also be given as OIDs.
+type Usage struct {
+	KeyUsage    []string
+	ExtKeyUsage []string
+}
+
+// ServerUsage returns the usage of a TLS server leaf certificate.
+func ServerUsage() Usage {
+	return Usage{KeyUsage: []string{"digitalSignature"}, ExtKeyUsage: []string{"serverAuth"}}
+}
+
+// ClientUsage returns the usage of a TLS client leaf certificate.
+func ClientUsage() Usage {
+	return Usage{KeyUsage: []string{"digitalSignature"}, ExtKeyUsage: []string{"clientAuth"}}
+}
+
+// extensions returns the extension file lines for u.
+func (u Usage) extensions() []string {
+	var lines []string
+	if len(u.KeyUsage) > 0 {
+		lines = append(lines, "keyUsage=critical,"+strings.Join(u.KeyUsage, ","))
+	}
+	if len(u.ExtKeyUsage) > 0 {
+		lines = append(lines, "extendedKeyUsage="+strings.Join(u.ExtKeyUsage, ","))
+	}
+	return lines
+}
+
+// CopyExtensions selects which CSR extensions are copied into a certificate.
+type CopyExtensions string
+
+const (
+	// CopyExtensionsNone ignores extensions in the CSR. It is the default.
+	CopyExtensionsNone CopyExtensions = "none"
+	// CopyExtensionsCopy copies extensions not already set by the signer.
+	CopyExtensionsCopy CopyExtensions = "copy"
+	// CopyExtensionsAll copies all extensions, replacing those set by the
+	// signer except the ones written by SignCertificateWithOptions itself.
+	CopyExtensionsAll CopyExtensions = "copyall"
+)
+
+// SignCertificateWithOptions signs the certificate request in csrFile with the
+// CA certificate and key, writing the certificate to outputFile. The CA
+// certificate and key may each be PEM or DER encoded. If the CA key
+// passphrase is wrong, the returned error wraps ErrBadPassphrase.
+//
+// It is safe to call concurrently, also against the same CA: each call uses
+// its own extension file, and calls sharing a serial file take turns, so
+// every certificate gets a distinct serial number. That coordination only
+// covers this process; other processes signing with the same serial file at
+// the same time must use Serial or a SerialFile of their own.
+func SignCertificateWithOptions(csrFile, caCertFile, caKeyFile, outputFile string, opts SignOptions) error {
+	_, err := SignCertificateSerial(csrFile, caCertFile, caKeyFile, outputFile, opts)
+	return err
+}
+
+// SignCertificateSerial is like SignCertificateWithOptions but also returns
+// the serial number of the issued certificate in upper-case hex, as in
+// CertInfo.Serial, e.g. to record it for revocation. The serial is taken from
+// opts.Serial or the serial file, so openssl is not run a second time.
+func SignCertificateSerial(csrFile, caCertFile, caKeyFile, outputFile string, opts SignOptions) (string, error) {
+	if err := checkInputFiles(csrFile, caCertFile, caKeyFile); err != nil {
+		return "", err
+	}
+	return signCertificate([]string{"-req", "-in", csrFile}, caCertFile, caKeyFile, outputFile, opts)
+}
+
+// signCertificate runs openssl x509 with the CA certificate and key, taking
+// the subject and public key from the given input arguments.
+func signCertificate(input []string, caCertFile, caKeyFile, outputFile string, opts SignOptions) (string, error) {
+	altName, err := subjectAltName(opts.SPIFFEID, opts.SANs)
+	if err != nil {
+		return "", err
+	}
+	if opts.Digest != "" {
+		if err := validateSigningDigest(opts.Digest); err != nil {
+			return "", err
+		}
+	}
+	validity, err := validityArgs(opts.Days, opts.Validity, opts.NotBefore, opts.NotAfter)
+	if err != nil {
+		return "", err
+	}
+	if opts.Serial != nil && opts.SerialFile != "" {
+		return "", errors.New("serial number and serial file cannot both be set")
+	}
+	if opts.Serial != nil && opts.Serial.Sign() < 0 {
+		return "", fmt.Errorf("serial number %s is negative", opts.Serial)
+	}
+
+	// Collect the extensions for the temporary extension file
+	var extensions []string
+	if altName != "" {
+		extensions = append(extensions, "subjectAltName="+altName)
+	}
+	usage := opts.Usage
+	if opts.IsCA {
+		constraints := "basicConstraints=critical,CA:TRUE"
+		if opts.MaxPathLen >= 0 {
+			constraints += fmt.Sprintf(",pathlen:%d", opts.MaxPathLen)
+		}
+		extensions = append(extensions, constraints)
+		if len(usage.KeyUsage) == 0 {
+			usage.KeyUsage = []string{"keyCertSign", "cRLSign"}
+		}
+	}
+	extensions = append(extensions, usage.extensions()...)
+	if opts.OmitKeyIdentifiers {
+		// OpenSSL 3 adds both by default whenever extensions are written
+		extensions = append(extensions, "subjectKeyIdentifier=none", "authorityKeyIdentifier=none")
+	} else {
+		extensions = append(extensions, "subjectKeyIdentifier=hash", "authorityKeyIdentifier=keyid,issuer")
+	}
+	extFile, cleanup, err := writeTempFile("extfile-*.conf", []byte(strings.Join(extensions, "\n")+"\n"))
+	if err != nil {
+		return "", fmt.Errorf("failed to write temporary extension file: %w", err)
+	}
+	defer cleanup()
+
+	// Prepare the command to sign the certificate
+	args := []string{"x509"}
+	args = append(args, input...)
+	args = append(args,
+		"-extfile", extFile, // Use the temporary extension file
+		"-CA", caCertFile,
+		"-CAkey", caKeyFile,
+		"-out", outputFile,
+	)
+	args = append(args, validity...)
+	// openssl 3 detects DER input by itself, but older versions need to be told
+	if format, err := detectFormat(caCertFile); err == nil && format == "DER" {
+		args = append(args, "-CAform", "DER")
+	}
+	if format, err := detectFormat(caKeyFile); err == nil && format == "DER" {
+		args = append(args, "-CAkeyform", "DER")
+	}
+	// The serial file is always named explicitly, so that the file locked
+	// and read back below is the one openssl uses
+	serialFile := opts.SerialFile
+	switch {
+	case opts.Serial != nil:
+		args = append(args, "-set_serial", "0x"+opts.Serial.Text(16))
+	default:
+		if serialFile == "" {
+			serialFile = defaultSerialFile(caCertFile)
+		}
+		args = append(args, "-CAserial", serialFile, "-CAcreateserial")
+	}
+	if opts.Digest != "" {
+		args = append(args, opts.Digest.flag())
+	}
+	switch opts.CopyExtensions {
+	case "", CopyExtensionsNone:
+	case CopyExtensionsCopy, CopyExtensionsAll:
+		args = append(args, "-copy_extensions", string(opts.CopyExtensions))
+	default:
+		return "", fmt.Errorf("unsupported copy extensions mode %q", opts.CopyExtensions)
+	}
+	if opts.CAKeyPassphrase != nil && opts.CAKeyPassphraseFunc != nil {
+		return "", errors.New("CA key passphrase and passphrase func cannot both be set")
+	}
+	if opts.CAKeyPassphrase != nil || opts.CAKeyPassphraseFunc != nil {
+		args = append(args, "-passin", "stdin")
+	}
+	args = append(args, opts.ExtraArgs...)
+	cmd := opensslCommand(args...)
+	cmd.Stdin = opts.CAKeyPassphrase
+	if opts.CAKeyPassphraseFunc != nil {
+		r, wipe, err := opts.CAKeyPassphraseFunc.reader()
+		if err != nil {
+			return "", err
+		}
+		defer wipe()
+		cmd.Stdin = r
+	}
+
+	// openssl reads and rewrites the serial file without locking it, so
+	// concurrent signings against the same file must take turns
+	if serialFile != "" {
+		unlock := lockSerialFile(serialFile)
+		defer unlock()
+	}
+
+	// Execute the command and check for errors
+	if err := classifyPassphraseError(runCommand(cmd, "Failed to sign certificate")); err != nil {
+		return "", err
+	}
+
+	// The serial file holds the serial just issued, and the lock keeps
+	// other signings from advancing it in the meantime
+	if opts.Serial != nil {
+		return formatSerial(opts.Serial), nil
+	}
+	data, err := os.ReadFile(serialFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read serial file: %w", err)
+	}
+	return strings.ToUpper(strings.TrimSpace(string(data))), nil
+}
+
+// formatSerial formats a serial number the way openssl prints it: upper-case
+// hex with an even number of digits.
+func formatSerial(serial *big.Int) string {
+	hex := strings.ToUpper(serial.Text(16))
+	if len(hex)%2 != 0 {
+		hex = "0" + hex
+	}
+	return hex
+}
+
+// SignCertificateTo is like SignCertificateWithOptions but writes the
+// PEM-encoded certificate to w instead of a file.
+func SignCertificateTo(w io.Writer, csrFile, caCertFile, caKeyFile string, opts SignOptions) error {
+	return writeThroughTempFile(w, "cert-*.pem", func(outputFile string) error {
+		return SignCertificateWithOptions(csrFile, caCertFile, caKeyFile, outputFile, opts)
+	})
+}
+
+// writeThroughTempFile runs generate with the path of a new temporary file,
+// copies the file to w and removes it.
+func writeThroughTempFile(w io.Writer, pattern string, generate func(path string) error) error {
+	if dryRun() {
+		return generate(dryRunTempPath(pattern))
+	}
+	tmp, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := generate(tmp.Name()); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, tmp); err != nil {
+		return fmt.Errorf("failed to copy output: %w", err)
+	}
+	return nil
+}
+
+// serialFileLocks maps serial file paths to the *sync.Mutex guarding them.
+var serialFileLocks sync.Map
+
+// lockSerialFile serializes access to a CA serial file within this process
+// and returns the function that releases it. The path is resolved so that
+// different spellings of the same file share a lock; the file itself may not
+// exist yet, so only its directory is resolved through symlinks.
+func lockSerialFile(path string) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	value, _ := serialFileLocks.LoadOrStore(path, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// defaultSerialFile returns the serial file used for a CA certificate when
+// SignOptions.SerialFile is empty: the certificate path with the extension of
+// its file name replaced by ".srl". openssl's own default instead cuts the
+// path at its last dot, which may lie in a directory name.
+func defaultSerialFile(caCertFile string) string {
+	return strings.TrimSuffix(caCertFile, filepath.Ext(caCertFile)) + ".srl"
+}
+
+// InitSerial writes the serial number file at path so that the next
+// certificate signed with it as SignOptions.SerialFile gets serial start, and
+// the ones after it start+1, start+2 and so on, e.g. for golden-file tests.
+// An existing file is overwritten. openssl increments the number before using
+// it, so the file holds start-1. start must be positive.
+func InitSerial(path string, start *big.Int) error {
+	if start == nil || start.Sign() <= 0 {
+		return fmt.Errorf("serial number %s is not positive", start)
+	}
+	previous := new(big.Int).Sub(start, big.NewInt(1))
+
+	unlock := lockSerialFile(path)
+	defer unlock()
+	if err := os.WriteFile(path, []byte(formatSerial(previous)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write serial file: %w", err)
+	}
+	return nil
+}
+
+// StartServer starts the OpenSSL server with the specified certificate and key
+// on port 4433, returning once it is accepting connections.
+func StartServer(certFile string, keyFile string, caFile string) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
+	cmd, stdinPipe, stdoutPipe, _, err := StartServerOnPort(4433, certFile, keyFile, caFile)
+	return cmd, stdinPipe, stdoutPipe, err
+}
+
+// StartServerOnPort starts the OpenSSL server listening on the given port and
+// returns the port it is bound to. If port is 0 the OS picks a free port.
+func StartServerOnPort(port int, certFile, keyFile, caFile string) (*exec.Cmd, io.WriteCloser, io.ReadCloser, int, error) {
+	return StartServerWithOptions(WithPort(port), WithCert(certFile), WithKey(keyFile), WithCAFile(caFile))
+}
+
+// ClientAuth controls whether s_server asks clients for a certificate.
+type ClientAuth int
+
+const (
+	// ClientAuthRequire fails the handshake unless the client presents a
+	// valid certificate (-Verify 1). It is the default.
+	ClientAuthRequire ClientAuth = iota
+	// ClientAuthRequest asks for a client certificate but does not require
+	// one (-verify 1). A certificate that is sent must still be valid.
+	ClientAuthRequest
+	// ClientAuthNone does not ask for a client certificate.
+	ClientAuthNone
+)
+
+// String returns the name of the client authentication mode.
+func (c ClientAuth) String() string {
+	switch c {
+	case ClientAuthRequire:
+		return "require"
+	case ClientAuthRequest:
+		return "request"
+	case ClientAuthNone:
+		return "none"
+	default:
+		return fmt.Sprintf("ClientAuth(%d)", int(c))
+	}
+}
+
+// ServerMode selects how s_server handles application data.
+type ServerMode int
+
+const (
+	// ServerModeStatus answers HTTP requests with a status page describing
+	// the connection (-www). It is the default.
+	ServerModeStatus ServerMode = iota
+	// ServerModeEcho exchanges raw application data: s_server prints what
+	// it receives on stdout and sends what is written to its stdin.
+	ServerModeEcho
+	// ServerModeFiles serves files from the working directory of s_server
+	// in answer to HTTP GET requests (-WWW).
+	ServerModeFiles
+)
+
+// String returns the name of the server mode.
+func (m ServerMode) String() string {
+	switch m {
+	case ServerModeStatus:
+		return "status"
+	case ServerModeEcho:
+		return "echo"
+	case ServerModeFiles:
+		return "files"
+	default:
+		return fmt.Sprintf("ServerMode(%d)", int(m))
+	}
+}
+
+// TLSVersion is a TLS protocol version as understood by -min_protocol and -max_protocol.
+type TLSVersion string
+
+const (
+	VersionTLS12  TLSVersion = "TLSv1.2"
+	VersionTLS13  TLSVersion = "TLSv1.3"
+	VersionDTLS12 TLSVersion = "DTLSv1.2"
+)
+
+// protocolArgs returns the openssl flags selecting the protocol versions. With
+// no bounds set, TLS 1.3 only is used, or any DTLS version if dtls is set. Key
+// exchange groups such as the PQ KEM groups only apply to TLS 1.3, so a
+// warning is logged if they are requested while TLS 1.3 is ruled out. DTLS
+// 1.2 only uses groups to pick the ECDHE curve.
+func protocolArgs(dtls bool, minVersion, maxVersion TLSVersion, groups []string) []string {
+	var args []string
+	if dtls {
+		args = append(args, "-dtls")
+	} else if minVersion == "" && maxVersion == "" {
+		return []string{"-tls1_3"}
+	}
+	if len(groups) > 0 && !dtls && maxVersion != "" && maxVersion != VersionTLS13 {
+		logf("Warning: groups %s requested but TLS 1.3 is disabled by max protocol %s", strings.Join(groups, ":"), maxVersion)
+	}
+
+	if minVersion != "" {
+		args = append(args, "-min_protocol", string(minVersion))
+	}
+	if maxVersion != "" {
+		args = append(args, "-max_protocol", string(maxVersion))
+	}
+	return args
+}
+
+// serverOptions holds the settings collected from ServerOption values.
+type serverOptions struct {
+	port        int
+	certFile    string
+	keyFile     string
+	chainFile   string
+	caFile      string
+	clientAuth  ClientAuth
+	groups      []string
+	alpn        []string
+	minProtocol TLSVersion
+	maxProtocol TLSVersion
+	keyLogFile  string
+	dtls        bool
+	mode        ServerMode
+	dhParamFile string
+	noTickets   bool
+	logLines    chan<- string
+	extraArgs   []string
+}
+
+// ServerOption configures StartServerWithOptions.
+type ServerOption func(*serverOptions)
+
+// WithPort sets the port s_server listens on. The default is 4433; 0 lets the
+// OS pick a free port.
+func WithPort(port int) ServerOption {
+	return func(o *serverOptions) { o.port = port }
+}
+
+// WithCert sets the server certificate file.
+func WithCert(certFile string) ServerOption {
+	return func(o *serverOptions) { o.certFile = certFile }
+}
+
+// WithKey sets the server private key file. Without it s_server reads the
+// key from the certificate file, as written by WriteCombinedPEM.
+func WithKey(keyFile string) ServerOption {
+	return func(o *serverOptions) { o.keyFile = keyFile }
+}
+
+// WithCertChain sets a file of intermediate CA certificates that s_server
+// sends after its certificate, so clients that only trust the root can build
+// the full path.
+func WithCertChain(chainFile string) ServerOption {
+	return func(o *serverOptions) { o.chainFile = chainFile }
+}
+
+// WithCAFile sets the CA certificates used to verify client certificates.
+func WithCAFile(caFile string) ServerOption {
+	return func(o *serverOptions) { o.caFile = caFile }
+}
+
+// WithClientAuth sets the client certificate policy. The default is ClientAuthRequire.
+func WithClientAuth(mode ClientAuth) ServerOption {
+	return func(o *serverOptions) { o.clientAuth = mode }
+}
+
+// WithGroups sets the TLS key exchange groups offered by the server, in order
+// of preference, e.g. "X25519MLKEM768", "mlkem768".
+func WithGroups(groups ...string) ServerOption {
+	return func(o *serverOptions) { o.groups = groups }
+}
+
+// WithALPN sets the application protocols the server accepts, e.g. "h2", "http/1.1".
+func WithALPN(protocols ...string) ServerOption {
+	return func(o *serverOptions) { o.alpn = protocols }
+}
+
+// WithProtocolVersions bounds the TLS versions the server accepts. Either
+// bound may be empty. By default only TLS 1.3 is accepted.
+func WithProtocolVersions(minVersion, maxVersion TLSVersion) ServerOption {
+	return func(o *serverOptions) {
+		o.minProtocol = minVersion
+		o.maxProtocol = maxVersion
+	}
+}
+
+// WithKeyLogFile makes s_server append the TLS secrets of each connection to
+// path in the NSS key log format, which Wireshark can use to decrypt captured
+// traffic. Only use it for debugging: the file exposes all session keys.
+func WithKeyLogFile(path string) ServerOption {
+	return func(o *serverOptions) { o.keyLogFile = path }
+}
+
+// WithDTLS makes s_server accept DTLS over UDP instead of TLS over TCP.
+// Protocol bounds set with WithProtocolVersions then refer to DTLS versions,
+// e.g. VersionDTLS12. The server prints received data instead of serving
+// HTTP, since -www is not available over DTLS.
+func WithDTLS() ServerOption {
+	return func(o *serverOptions) { o.dtls = true }
+}
+
+// WithServerMode sets how the server handles application data. The default
+// is ServerModeStatus. Over DTLS only ServerModeEcho is available, and
+// ServerModeStatus falls back to it.
+func WithServerMode(mode ServerMode) ServerOption {
+	return func(o *serverOptions) { o.mode = mode }
+}
+
+// WithDHParams sets the Diffie-Hellman parameters s_server uses for DHE
+// cipher suites, as written by GenerateDHParams. They only apply to TLS 1.2,
+// so they are ignored unless WithProtocolVersions allows it. Without them
+// s_server uses built-in parameters.
+func WithDHParams(dhParamFile string) ServerOption {
+	return func(o *serverOptions) { o.dhParamFile = dhParamFile }
+}
+
+// WithoutSessionTickets disables session resumption, so that every
+// connection does a full handshake, e.g. to measure key exchange with a given
+// group reliably. Besides -no_ticket, which on its own still lets TLS 1.3
+// clients resume through stateful tickets, the session cache is turned off
+// and no TLS 1.3 tickets are sent. Resumption is enabled by default.
+func WithoutSessionTickets() ServerOption {
+	return func(o *serverOptions) { o.noTickets = true }
+}
+
+// WithLogLines forwards each line s_server prints, including its -state
+// output and errors, to lines, and closes lines once the output ends when
+// the process exits. The stdout reader returned by StartServerWithOptions is
+// then empty. The caller must keep receiving from lines, otherwise s_server
+// blocks as soon as its output pipe fills up.
+func WithLogLines(lines chan<- string) ServerOption {
+	return func(o *serverOptions) { o.logLines = lines }
+}
+
+// WithExtraArgs appends args verbatim to the s_server command line, for flags
+// the package does not wrap. They are neither validated nor escaped.
+func WithExtraArgs(args ...string) ServerOption {
+	return func(o *serverOptions) { o.extraArgs = append(o.extraArgs, args...) }
+}
+
+// StartServerWithOptions starts the OpenSSL server and returns the port it is
+// bound to. The returned stdout reader also carries s_server's stderr. It
+// blocks until s_server prints its "ACCEPT" line, so clients may connect as
+// soon as it returns, or fails once the configured ServerStartTimeout
+// elapses. If the port is 0 the OS picks a free port, which is parsed from
+// that line. Output consumed while waiting is still returned by the stdout
+// reader.
+func StartServerWithOptions(opts ...ServerOption) (*exec.Cmd, io.WriteCloser, io.ReadCloser, int, error) {
+	o := serverOptions{port: 4433}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The key is optional, s_server falls back to the certificate file
+	if err := checkInputFiles(o.certFile); err != nil {
+		return nil, nil, nil, 0, err
+	}
+	if o.keyFile != "" {
+		if err := checkInputFiles(o.keyFile); err != nil {
+			return nil, nil, nil, 0, err
+		}
+	}
+
+	args := []string{"s_server", "-accept", strconv.Itoa(o.port), "-state", "-cert", o.certFile}
+	if o.keyFile != "" {
+		args = append(args, "-key", o.keyFile)
+	}
+	if o.chainFile != "" {
+		args = append(args, "-cert_chain", o.chainFile)
+	}
+	args = append(args, protocolArgs(o.dtls, o.minProtocol, o.maxProtocol, o.groups)...)
+	switch o.clientAuth {
+	// s_server only reports verify errors unless told to fail on them
+	case ClientAuthRequire:
+		args = append(args, "-Verify", "1", "-verify_return_error")
+	case ClientAuthRequest:
+		args = append(args, "-verify", "1", "-verify_return_error")
+	case ClientAuthNone:
+	default:
+		return nil, nil, nil, 0, fmt.Errorf("unsupported client authentication mode %s", o.clientAuth)
+	}
+	if o.caFile != "" {
+		args = append(args, "-CAfile", o.caFile)
+	}
+	if len(o.groups) > 0 {
+		args = append(args, "-groups", strings.Join(o.groups, ":"))
+	}
+	if len(o.alpn) > 0 {
+		args = append(args, "-alpn", strings.Join(o.alpn, ","))
+	}
+	if o.keyLogFile != "" {
+		args = append(args, "-keylogfile", o.keyLogFile)
+	}
+	if o.dhParamFile != "" {
+		args = append(args, "-dhparam", o.dhParamFile)
+		if !o.dtls && (o.minProtocol == VersionTLS13 || o.minProtocol == "" && o.maxProtocol == "") {
+			logf("Warning: DH parameters %s set but TLS 1.2 is disabled", o.dhParamFile)
+		}
+	}
+	if o.noTickets {
+		args = append(args, "-no_ticket", "-no_cache", "-num_tickets", "0")
+	}
+	switch o.mode {
+	case ServerModeStatus:
+		// s_server cannot answer HTTP over DTLS and echoes what it receives instead
+		if !o.dtls {
+			args = append(args, "-www")
+		}
+	case ServerModeEcho:
+	case ServerModeFiles:
+		if o.dtls {
+			return nil, nil, nil, 0, errors.New("file server mode is not available over DTLS")
+		}
+		args = append(args, "-WWW")
+	default:
+		return nil, nil, nil, 0, fmt.Errorf("unsupported server mode %s", o.mode)
+	}
+	args = append(args, o.extraArgs...)
+	cmd := opensslCommand(args...)
+	if err := dryRunError(cmd); err != nil {
+		return nil, nil, nil, 0, err
+	}
+
+	// Create the StdoutPipe before starting the command
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		logf("Error creating stdout pipe: %v", err)
+		return nil, nil, nil, 0, err
+	}
+	// Handshake errors are reported on stderr, so send it down the same pipe
+	cmd.Stderr = cmd.Stdout
+
+	// Create the StdinPipe before starting the command
+	stdinPipe, err := cmd.StdinPipe()
+	if err != nil {
+		logf("Error creating stdin pipe: %v", err)
+		return nil, nil, nil, 0, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		logf("Error starting OpenSSL s_server: %v", err)
+		return nil, nil, nil, 0, wrapNotFound(err)
+	}
+
+	stdout, boundPort, err := WaitServerReady(stdoutPipe, o.port, serverStartTimeout())
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, nil, nil, 0, err
+	}
+	if o.logLines != nil {
+		go forwardLines(stdout, o.logLines)
+		stdout = struct {
+			io.Reader
+			io.Closer
+		}{strings.NewReader(""), stdout}
+	}
+	return cmd, stdinPipe, stdout, boundPort, nil
+}
+
+// forwardLines sends each line read from r to lines, without the line ending,
+// and closes lines when r is exhausted.
+func forwardLines(r io.Reader, lines chan<- string) {
+	defer close(lines)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines <- strings.TrimRight(line, "\r\n")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// WaitServerReady reads s_server output from stdout until it reports that it
+// is accepting connections and returns the bound port together with a reader
+// that replays the consumed output. requestedPort is the port passed to
+// -accept. If s_server exits first or timeout elapses, the error wraps
+// ErrServerNotReady; the caller is responsible for stopping the process.
+// StartServerWithOptions already calls it, so it is only needed for s_server
+// processes started by other means.
+func WaitServerReady(stdout io.ReadCloser, requestedPort int, timeout time.Duration) (io.ReadCloser, int, error) {
+	type acceptResult struct {
+		stdout io.ReadCloser
+		port   int
+		err    error
+	}
+	done := make(chan acceptResult, 1)
+	go func() {
+		replay, port, err := readAcceptPort(stdout, requestedPort)
+		done <- acceptResult{replay, port, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, 0, fmt.Errorf("%w: %w", ErrServerNotReady, res.err)
+		}
+		return res.stdout, res.port, nil
+	case <-time.After(timeout):
+		return nil, 0, fmt.Errorf("%w: s_server did not start listening within %s", ErrServerNotReady, timeout)
+	}
+}
+
+// readAcceptPort reads s_server output until the "ACCEPT" line and returns the
+// port together with a reader that replays everything consumed. s_server only
+// prints "ACCEPT [host]:port" when it picked the port itself, otherwise the
+// bare "ACCEPT" line means it is listening on the requested port.
+func readAcceptPort(stdoutPipe io.ReadCloser, requestedPort int) (io.ReadCloser, int, error) {
+	line, stdout, consumed, err := readUntilLine(stdoutPipe, "ACCEPT")
+	if err != nil {
+		return nil, 0, fmt.Errorf("s_server exited before accepting connections: %w\n%s", err, consumed)
+	}
+	port := requestedPort
+	if field := strings.TrimSpace(strings.TrimPrefix(line, "ACCEPT")); field != "" {
+		var convErr error
+		port, convErr = strconv.Atoi(field[strings.LastIndex(field, ":")+1:])
+		if convErr != nil {
+			return nil, 0, fmt.Errorf("failed to parse s_server port from %q: %w", line, convErr)
+		}
+	}
+	if port == 0 {
+		return nil, 0, fmt.Errorf("s_server did not report the port it is listening on: %q", line)
+	}
+	return stdout, port, nil
+}
+
+// readUntilLine reads output until a line starting with prefix and returns
+// that line together with a reader that replays everything consumed. If the
+// output ends first, the error is returned along with what was read.
+func readUntilLine(stdoutPipe io.ReadCloser, prefix string) (string, io.ReadCloser, string, error) {
+	reader := bufio.NewReader(stdoutPipe)
+	var consumed strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		consumed.WriteString(line)
+		if strings.HasPrefix(line, prefix) {
+			replay := io.MultiReader(strings.NewReader(consumed.String()), reader)
+			return strings.TrimSpace(line), struct {
+				io.Reader
+				io.Closer
+			}{replay, stdoutPipe}, consumed.String(), nil
+		}
+		if err != nil {
+			return "", nil, consumed.String(), err
+		}
+	}
+}
+
+// StopServer stops a server started by StartServer. It sends SIGTERM, waits up
+// to the configured StopGracePeriod and then kills the process. On Windows the
+// process is killed straight away. Waiting on the process also closes the
+// stdin and stdout pipes returned by StartServer.
+func StopServer(cmd *exec.Cmd) error {
+	if cmd == nil || cmd.Process == nil {
+		return errors.New("server process was not started")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	return stopProcess(cmd.Process, done)
+}
+
+// stopProcess signals p to terminate and waits for the exit status of the
+// process on done, killing it once the grace period elapses.
+func stopProcess(p *os.Process, done <-chan error) error {
+	if err := terminateProcess(p); err != nil {
+		_ = p.Kill()
+	}
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(stopGracePeriod()):
+		_ = p.Kill()
+		err = <-done
+	}
+
+	// A non-zero exit is expected after being signalled
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
+
+// StartClient connects to the OpenSSL server using the specified client certificate and key.
+func StartClient(address, certFile, keyFile, caCertFile string) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
+	return StartClientWithOptions(address, ClientOptions{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   caCertFile,
+	})
+}
+
+// ClientOptions holds the settings for StartClientWithOptions.
+type ClientOptions struct {
+	// CertFile and KeyFile are the client certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// CAFile holds the CA certificates used to verify the server. If it or
+	// CAPath is set, a server certificate that does not verify aborts the
+	// handshake, and CheckHandshakeOutput reports it as ErrCertificateVerify.
+	// Without either, openssl's default trust store is used, and s_client
+	// completes the handshake with a server it cannot verify, which
+	// CheckHandshakeOutput still reports as ErrCertificateVerify.
+	CAFile string
+
+	// CAPath is a directory of CA certificates named by their subject hash,
+	// used alongside or instead of CAFile.
+	CAPath string
+
+	// VerifyHostname, if set, is checked against the server certificate. A
+	// mismatch aborts the handshake, and CheckHandshakeOutput reports it as
+	// ErrHostnameMismatch.
+	VerifyHostname string
+
+	// ServerName is sent as the SNI server name, which servers use to select
+	// a certificate. It is not verified against the certificate; that is what
+	// VerifyHostname is for. It defaults to VerifyHostname if set, otherwise
+	// to the host part of the address unless that is an IP address.
+	ServerName string
+
+	// Groups sets the TLS key exchange groups offered by the client, in order
+	// of preference, e.g. "X25519MLKEM768", "mlkem768".
+	Groups []string
+
+	// MinProtocol and MaxProtocol bound the TLS versions the client offers.
+	// Either may be empty. By default only TLS 1.3 is offered.
+	MinProtocol TLSVersion
+	MaxProtocol TLSVersion
+
+	// ALPN lists the application protocols offered by the client, e.g. "h2",
+	// "http/1.1". Use NegotiatedALPN to read the protocol the server picked.
+	ALPN []string
+
+	// ConnectTimeout, if positive, bounds how long StartClientWithOptions
+	// waits for the TCP connection to be established. If it expires, the
+	// client is killed and the error wraps ErrConnectTimeout. Handshake
+	// failures after connecting are reported through the output as usual.
+	ConnectTimeout time.Duration
+
+	// ConnectRetries is how many more times StartClientWithOptions and
+	// Handshake try to connect when the connection is refused, e.g. because
+	// the server is not listening yet. Other failures are not retried. Each
+	// retry waits twice as long as the previous one, starting with
+	// ConnectRetryBackoff or 100ms if that is zero. With retries enabled,
+	// StartClientWithOptions waits for the connection like with
+	// ConnectTimeout.
+	ConnectRetries      int
+	ConnectRetryBackoff time.Duration
+
+	// KeyLogFile, if set, receives the TLS secrets of the connection in the
+	// NSS key log format, as with WithKeyLogFile on the server side.
+	KeyLogFile string
+
+	// DTLS connects over UDP using DTLS instead of TLS over TCP. MinProtocol
+	// and MaxProtocol then refer to DTLS versions, e.g. VersionDTLS12.
+	DTLS bool
+
+	// RequestOCSP asks the server to staple an OCSP response. ParseHandshake
+	// reports the outcome in HandshakeInfo.OCSP.
+	RequestOCSP bool
+
+	// ExtraArgs are appended verbatim to the s_client command line,
+	// unvalidated and unescaped.
+	ExtraArgs []string
+}
+
+// defaultConnectRetryBackoff is the wait before the first connection retry
+// when ClientOptions.ConnectRetryBackoff is not set.
+const defaultConnectRetryBackoff = 100 * time.Millisecond
+
+// StartClientWithOptions connects to the OpenSSL server at address using the
+// given options. The returned stdout reader also carries s_client's stderr, so
+// it can be passed to CheckHandshakeOutput, or to WaitHandshake to block until
+// the handshake has completed or failed.
+func StartClientWithOptions(address string, opts ClientOptions) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
+	delay := connectRetryBackoff(opts)
+	for attempt := 0; ; attempt++ {
+		cmd, stdin, stdout, err := startClient(address, opts)
+		if err == nil || attempt >= opts.ConnectRetries || !errors.Is(err, ErrConnectionRefused) {
+			return cmd, stdin, stdout, err
+		}
+		logf("Connection to %s refused, retrying in %s", address, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// connectRetryBackoff returns the wait before the first connection retry.
+func connectRetryBackoff(opts ClientOptions) time.Duration {
+	if opts.ConnectRetryBackoff > 0 {
+		return opts.ConnectRetryBackoff
+	}
+	return defaultConnectRetryBackoff
+}
+
+// isConnectionRefused reports whether s_client output shows that the
+// connection was refused.
+func isConnectionRefused(output string) bool {
+	return strings.Contains(strings.ToLower(output), "connection refused")
+}
+
+// startClient makes a single attempt of StartClientWithOptions.
+func startClient(address string, opts ClientOptions) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
+	cmd := opensslCommand(clientArgs(address, opts)...)
+	if err := dryRunError(cmd); err != nil {
+		return nil, nil, nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logf("Error creating stdout pipe: %v", err)
+		return nil, nil, nil, err
+	}
+	// Handshake errors are reported on stderr, so send it down the same pipe
+	cmd.Stderr = cmd.Stdout
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		logf("Error creating stdin pipe: %v", err)
+		return nil, nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		logf("Error starting OpenSSL s_client: %v", err)
+		return nil, nil, nil, wrapNotFound(err)
+	}
+	if opts.ConnectTimeout <= 0 && opts.ConnectRetries <= 0 {
+		return cmd, stdin, stdout, nil
+	}
+
+	// Wait for s_client to report the connection before handing it over
+	type connectResult struct {
+		stdout   io.ReadCloser
+		consumed string
+		err      error
+	}
+	done := make(chan connectResult, 1)
+	go func() {
+		_, replay, consumed, err := readUntilLine(stdout, "CONNECTED(")
+		done <- connectResult{replay, consumed, err}
+	}()
+
+	var timeout <-chan time.Time
+	if opts.ConnectTimeout > 0 {
+		timeout = time.After(opts.ConnectTimeout)
+	}
+	select {
+	case res := <-done:
+		if res.err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			err := fmt.Errorf("s_client failed to connect to %s: %w\n%s", address, res.err, res.consumed)
+			if isConnectionRefused(res.consumed) {
+				err = fmt.Errorf("%w: %w", ErrConnectionRefused, err)
+			}
+			return nil, nil, nil, err
+		}
+		return cmd, stdin, res.stdout, nil
+	case <-timeout:
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, nil, nil, fmt.Errorf("%w: %s after %s", ErrConnectTimeout, address, opts.ConnectTimeout)
+	}
+}
+
+// clientArgs returns the s_client arguments for connecting to address with opts.
+func clientArgs(address string, opts ClientOptions) []string {
+	args := []string{"s_client", "-connect", address, "-state"}
+	if opts.CertFile != "" {
+		args = append(args, "-cert", opts.CertFile)
+	}
+	if opts.KeyFile != "" {
+		args = append(args, "-key", opts.KeyFile)
+	}
+	if opts.CAFile != "" {
+		args = append(args, "-CAfile", opts.CAFile)
+	}
+	if opts.CAPath != "" {
+		args = append(args, "-CApath", opts.CAPath)
+	}
+	args = append(args, protocolArgs(opts.DTLS, opts.MinProtocol, opts.MaxProtocol, opts.Groups)...)
+	if len(opts.ALPN) > 0 {
+		args = append(args, "-alpn", strings.Join(opts.ALPN, ","))
+	}
+	if opts.KeyLogFile != "" {
+		args = append(args, "-keylogfile", opts.KeyLogFile)
+	}
+	if opts.RequestOCSP {
+		args = append(args, "-status")
+	}
+	if opts.VerifyHostname != "" {
+		args = append(args, "-verify_hostname", opts.VerifyHostname)
+	}
+	// s_client otherwise carries on with a server certificate that failed
+	// the checks asked for
+	if opts.VerifyHostname != "" || opts.CAFile != "" || opts.CAPath != "" {
+		args = append(args, "-verify_return_error")
+	}
+	if serverName := clientServerName(address, opts); serverName != "" {
+		args = append(args, "-servername", serverName)
+	}
+	if len(opts.Groups) > 0 {
+		args = append(args, "-groups", strings.Join(opts.Groups, ":"))
+	}
+	return append(args, opts.ExtraArgs...)
+}
+
+// PeerCertificates connects to address, completes a handshake and returns the
+// PEM-encoded certificates presented by the server, leaf first. An error is
+// returned if the handshake fails or the server presents no certificates.
+func PeerCertificates(address string, opts ClientOptions) ([][]byte, error) {
+	cmd := opensslCommand(append(clientArgs(address, opts), "-showcerts")...)
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to retrieve peer certificates")
+	if err != nil {
+		return nil, err
+	}
+
+	var certs [][]byte
+	rest := []byte(output)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, pem.EncodeToMemory(block))
+		}
+	}
+	if len(certs) == 0 {
+		if err := CheckHandshakeOutput(output); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("server presented no certificates")
+	}
+	return certs, nil
+}
+
+// clientServerName returns the SNI server name to send for opts.
+func clientServerName(address string, opts ClientOptions) string {
+	if opts.ServerName != "" {
+		return opts.ServerName
+	}
+	if opts.VerifyHostname != "" {
+		return opts.VerifyHostname
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
+}
+
+// Run executes openssl with the given arguments and returns its standard output;
+// stderr is only reported, through an *OpenSSLError, if openssl fails.
+// It is an escape hatch for subcommands the package does not wrap.
+func Run(args ...string) (string, error) {
+	return RunContext(context.Background(), args...)
+}
+
+// RunContext is like Run but kills the openssl process when ctx is done.
+func RunContext(ctx context.Context, args ...string) (string, error) {
+	cmd := opensslCommandContext(ctx, args...)
+	return runCommandOutput(ctx, cmd, "Failed to run openssl")
+}
+
+// runCommand executes an exec.Command and discards its output on success.
+func runCommand(cmd *exec.Cmd, errorMessage string) error {
+	return runCommandContext(context.Background(), cmd, errorMessage)
+}
+
+// runCommandContext is like runCommand for a command created with ctx.
+func runCommandContext(ctx context.Context, cmd *exec.Cmd, errorMessage string) error {
+	_, err := runCommandOutput(ctx, cmd, errorMessage)
+	return err
+}
+
+// runCommandStdout executes an exec.Command created with ctx and returns its
+// stdout. Stderr is captured separately so diagnostics never end up in parsed
+// output. If the command fails because ctx is done, the returned error wraps
+// ctx.Err(); if openssl exits with a non-zero status, it wraps an *OpenSSLError
+// holding stderr.
+func runCommandStdout(ctx context.Context, cmd *exec.Cmd, errorMessage string) ([]byte, error) {
+	if err := dryRunError(cmd); err != nil {
+		return nil, err
+	}
+	logf("Running %s", strings.Join(cmd.Args, " "))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, fmt.Errorf("%s: %w", errorMessage, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%s: %w", errorMessage, &OpenSSLError{
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   stderr.String(),
+			Args:     cmd.Args[1:],
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errorMessage, wrapNotFound(err))
+	}
+	return output, nil
+}
+
+// runCommandOutput is like runCommandStdout but returns stdout as a string.
+func runCommandOutput(ctx context.Context, cmd *exec.Cmd, errorMessage string) (string, error) {
+	output, err := runCommandStdout(ctx, cmd, errorMessage)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// ValidateCertificate checks if the provided certificate is valid against the specified CA certificate.
+func ValidateCertificate(certFile, caCertFile string) error {
+	if err := checkInputFiles(certFile, caCertFile); err != nil {
+		return err
+	}
+	cmd := opensslCommand("verify", "-CAfile", caCertFile, certFile)
+	return runCommand(cmd, "Failed to validate certificate")
+}
+
+// ValidateCertificateChain checks certFile against the trusted CA certificates
+// in caFile, using the intermediates to build the chain. If verification
+// fails, the returned error wraps a *VerifyError naming the failing link.
+func ValidateCertificateChain(certFile, caFile string, intermediates []string) error {
+	return ValidateCertificateWithOptions(certFile, VerifyOptions{
+		CAFile:        caFile,
+		Intermediates: intermediates,
+	})
+}
+
+// VerifyOptions configures ValidateCertificateWithOptions.
+type VerifyOptions struct {
+	// CAFile holds the trusted CA certificates.
+	CAFile string
+
+	// CAPath is a directory of trusted CA certificates named by their subject
+	// hash, as laid out by openssl rehash. It may be combined with CAFile.
+	CAPath string
+
+	// Intermediates are untrusted certificate files used to build the chain.
+	Intermediates []string
+
+	// Depth limits the number of intermediate certificates allowed in the
+	// chain. Zero keeps openssl's default limit.
+	Depth int
+
+	// CRLFiles are certificate revocation lists checked against the leaf
+	// certificate, or the whole chain if CRLCheckAll is set. Verification of
+	// a revoked certificate fails with an error matching
+	// ErrCertificateRevoked.
+	CRLFiles    []string
+	CRLCheckAll bool
+
+	// Purpose, if set, also checks that the certificate chain may be used for
+	// that purpose, e.g. that a server certificate is not accepted as a client
+	// certificate. A mismatch fails with an error matching
+	// ErrInvalidPurpose.
+	Purpose Purpose
+
+	// ExtraArgs are added verbatim to the verify command line before the
+	// certificate, unvalidated and unescaped.
+	ExtraArgs []string
+}
+
+// ValidateCertificateWithOptions checks certFile against the trust anchors
+// in opts. If verification fails, the returned error wraps a *VerifyError
+// naming the failing link.
+func ValidateCertificateWithOptions(certFile string, opts VerifyOptions) error {
+	if err := checkInputFiles(certFile); err != nil {
+		return err
+	}
+	args := []string{"verify"}
+	if opts.Depth < 0 {
+		return fmt.Errorf("invalid verification depth %d", opts.Depth)
+	}
+	if opts.Depth > 0 {
+		args = append(args, "-verify_depth", strconv.Itoa(opts.Depth))
+	}
+	if opts.CAFile != "" {
+		args = append(args, "-CAfile", opts.CAFile)
+	}
+	if opts.CAPath != "" {
+		args = append(args, "-CApath", opts.CAPath)
+	}
+	for _, intermediate := range opts.Intermediates {
+		args = append(args, "-untrusted", intermediate)
+	}
+	for _, crlFile := range opts.CRLFiles {
+		args = append(args, "-CRLfile", crlFile)
+	}
+	if opts.CRLCheckAll {
+		args = append(args, "-crl_check_all")
+	} else if len(opts.CRLFiles) > 0 {
+		args = append(args, "-crl_check")
+	}
+	switch opts.Purpose {
+	case "":
+	case PurposeSSLServer, PurposeSSLClient:
+		args = append(args, "-purpose", string(opts.Purpose))
+	default:
+		return fmt.Errorf("unsupported verify purpose %q", opts.Purpose)
+	}
+	args = append(args, opts.ExtraArgs...)
+	args = append(args, certFile)
+
+	cmd := opensslCommand(args...)
+	return classifyVerifyError(runCommand(cmd, "Failed to validate certificate chain"))
+}
@@ -0,0 +1,254 @@
+package oqsopenssl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestCA creates an Ed25519 root CA in dir and returns its certificate and
+// key.
+func newTestCA(t *testing.T, dir string) (caCert, caKey string) {
+	t.Helper()
+	caCert = filepath.Join(dir, "ca.pem")
+	caKey = filepath.Join(dir, "ca.key")
+	if err := GeneratePrivateKey("ED25519", caKey); err != nil {
+		t.Fatalf("GeneratePrivateKey: %v", err)
+	}
+	if err := GenerateRootCertificate(caKey, caCert, "/CN=Test CA", "", "", 1); err != nil {
+		t.Fatalf("GenerateRootCertificate: %v", err)
+	}
+	return caCert, caKey
+}
+
+// newTestCSR creates an Ed25519 key and a certificate request for it in dir,
+// with the common name name.
+func newTestCSR(t *testing.T, dir, name string) string {
+	t.Helper()
+	csrFile := filepath.Join(dir, name+".csr")
+	if err := GenerateCSR("ED25519", filepath.Join(dir, name+".key"), csrFile, "/CN="+name, "", ""); err != nil {
+		t.Fatalf("GenerateCSR: %v", err)
+	}
+	return csrFile
+}
+
+// newTestIntermediate has the CA in caCert and caKey issue an intermediate CA
+// certificate with the common name name and path length limit maxPathLen, and
+// returns its certificate and key.
+func newTestIntermediate(t *testing.T, dir, name, caCert, caKey string, maxPathLen int) (cert, key string) {
+	t.Helper()
+	cert = filepath.Join(dir, name+".pem")
+	if err := SignCertificateWithOptions(newTestCSR(t, dir, name), caCert, caKey, cert, SignOptions{
+		Days:       1,
+		IsCA:       true,
+		MaxPathLen: maxPathLen,
+	}); err != nil {
+		t.Fatalf("signing intermediate %s: %v", name, err)
+	}
+	return cert, filepath.Join(dir, name+".key")
+}
+
+// readTestCertificate parses the PEM certificate in certFile.
+func readTestCertificate(t *testing.T, certFile string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("reading certificate: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s holds no PEM data", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", certFile, err)
+	}
+	return cert
+}
+
+// signConcurrently calls sign for 0 through n-1 at the same time and fails the
+// test if any call fails.
+func signConcurrently(t *testing.T, n int, sign func(i int) error) {
+	t.Helper()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sign(i)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("signing %d: %v", i, err)
+		}
+	}
+}
+
+// uniqueSerials returns the serial numbers of the certificates in certFiles,
+// in decimal, and fails the test if any two share one.
+func uniqueSerials(t *testing.T, certFiles []string) map[string]string {
+	t.Helper()
+	serials := make(map[string]string, len(certFiles))
+	for _, certFile := range certFiles {
+		serial := readTestCertificate(t, certFile).SerialNumber.String()
+		if other, ok := serials[serial]; ok {
+			t.Errorf("%s and %s share serial %s", other, certFile, serial)
+		}
+		serials[serial] = certFile
+	}
+	return serials
+}
+
+func TestSignCertificateConcurrentSerials(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCA(t, dir)
+	csrFile := newTestCSR(t, dir, "leaf")
+
+	const signings = 20
+	certFiles := make([]string, signings)
+	for i := range certFiles {
+		certFiles[i] = filepath.Join(dir, fmt.Sprintf("leaf%d.pem", i))
+	}
+	signConcurrently(t, signings, func(i int) error {
+		return SignCertificate(csrFile, caCert, caKey, "", certFiles[i], 1)
+	})
+	serials := uniqueSerials(t, certFiles)
+
+	// The serial file must still hold a single serial openssl can read
+	data, err := os.ReadFile(defaultSerialFile(caCert))
+	if err != nil {
+		t.Fatalf("reading serial file: %v", err)
+	}
+	last, ok := new(big.Int).SetString(strings.TrimSpace(string(data)), 16)
+	if !ok {
+		t.Fatalf("serial file holds %q, want a hexadecimal serial", data)
+	}
+	if _, ok := serials[last.String()]; !ok {
+		t.Errorf("serial file holds %q, want the last issued serial", data)
+	}
+}
+
+func TestSignIntermediateCA(t *testing.T) {
+	dir := t.TempDir()
+	rootCert, rootKey := newTestCA(t, dir)
+	intermediateCert, intermediateKey := newTestIntermediate(t, dir, "intermediate", rootCert, rootKey, 0)
+	leafCert := filepath.Join(dir, "leaf.pem")
+	if err := SignCertificate(newTestCSR(t, dir, "leaf"), intermediateCert, intermediateKey, "", leafCert, 1); err != nil {
+		t.Fatalf("signing leaf: %v", err)
+	}
+
+	opts := VerifyOptions{CAFile: rootCert, Intermediates: []string{intermediateCert}}
+	if err := ValidateCertificateWithOptions(leafCert, opts); err != nil {
+		t.Errorf("leaf does not validate through the intermediate: %v", err)
+	}
+	if err := ValidateCertificateWithOptions(leafCert, VerifyOptions{CAFile: rootCert}); err == nil {
+		t.Error("leaf validates without the intermediate")
+	}
+
+	// A path length of 0 allows the intermediate to sign leaves only
+	subCert, subKey := newTestIntermediate(t, dir, "sub", intermediateCert, intermediateKey, -1)
+	subLeafCert := filepath.Join(dir, "subleaf.pem")
+	if err := SignCertificate(newTestCSR(t, dir, "subleaf"), subCert, subKey, "", subLeafCert, 1); err != nil {
+		t.Fatalf("signing leaf of sub-CA: %v", err)
+	}
+	opts.Intermediates = append(opts.Intermediates, subCert)
+	var verifyErr *VerifyError
+	if err := ValidateCertificateWithOptions(subLeafCert, opts); !errors.As(err, &verifyErr) || verifyErr.Code != VerifyErrPathLengthExceeded {
+		t.Errorf("validating past the path length limit: got %v, want error %d", err, VerifyErrPathLengthExceeded)
+	}
+}
+
+func TestWithCertChain(t *testing.T) {
+	dir := t.TempDir()
+	rootCert, rootKey := newTestCA(t, dir)
+	intermediateCert, intermediateKey := newTestIntermediate(t, dir, "intermediate", rootCert, rootKey, 0)
+	leafCert := filepath.Join(dir, "localhost.pem")
+	if err := SignCertificate(newTestCSR(t, dir, "localhost"), intermediateCert, intermediateKey, "", leafCert, 1); err != nil {
+		t.Fatalf("signing leaf: %v", err)
+	}
+
+	cmd, stdin, _, port, err := StartServerWithOptions(
+		WithPort(0),
+		WithCert(leafCert),
+		WithKey(filepath.Join(dir, "localhost.key")),
+		WithCertChain(intermediateCert),
+		WithClientAuth(ClientAuthNone),
+	)
+	if err != nil {
+		t.Fatalf("StartServerWithOptions: %v", err)
+	}
+	defer StopServer(cmd)
+	defer stdin.Close()
+
+	certs, err := PeerCertificates(fmt.Sprintf("localhost:%d", port), ClientOptions{CAFile: rootCert})
+	if err != nil {
+		t.Fatalf("PeerCertificates: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("server presented %d certificates, want 2", len(certs))
+	}
+	for i, want := range []string{"localhost", "intermediate"} {
+		block, _ := pem.Decode(certs[i])
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("parsing certificate %d: %v", i, err)
+		}
+		if cert.Subject.CommonName != want {
+			t.Errorf("certificate %d has common name %q, want %q", i, cert.Subject.CommonName, want)
+		}
+	}
+}
+
+func TestSignCertificateStress(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCA(t, dir)
+	// Temporary extension files must not collide or be left behind
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	const signings = 50
+	csrFiles := make([]string, signings)
+	certFiles := make([]string, signings)
+	for i := range signings {
+		csrFiles[i] = newTestCSR(t, dir, fmt.Sprintf("leaf%d", i))
+		certFiles[i] = filepath.Join(dir, fmt.Sprintf("leaf%d.pem", i))
+	}
+	signConcurrently(t, signings, func(i int) error {
+		return SignCertificateWithOptions(csrFiles[i], caCert, caKey, certFiles[i], SignOptions{
+			SPIFFEID: fmt.Sprintf("spiffe://example.org/leaf%d", i),
+			Days:     1,
+		})
+	})
+	uniqueSerials(t, certFiles)
+
+	for i, certFile := range certFiles {
+		if err := ValidateCertificate(certFile, caCert); err != nil {
+			t.Errorf("leaf %d does not validate: %v", i, err)
+		}
+		cert := readTestCertificate(t, certFile)
+		if want := fmt.Sprintf("leaf%d", i); cert.Subject.CommonName != want {
+			t.Errorf("leaf %d has common name %q, want %q", i, cert.Subject.CommonName, want)
+		}
+		if want := fmt.Sprintf("spiffe://example.org/leaf%d", i); len(cert.URIs) != 1 || cert.URIs[0].String() != want {
+			t.Errorf("leaf %d has URIs %v, want %s", i, cert.URIs, want)
+		}
+	}
+
+	leftovers, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("reading temporary directory: %v", err)
+	}
+	for _, leftover := range leftovers {
+		t.Errorf("temporary file %s left behind", leftover.Name())
+	}
+}
@@ -0,0 +1,24 @@
+package oqsopenssl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PassphraseFunc supplies a passphrase on demand, e.g. from a secrets manager
+// or a terminal prompt. It is only called right before openssl needs the
+// passphrase, which is fed to openssl over a stdin pipe so it never appears in
+// the process list or on disk. The returned slice is zeroed once openssl has
+// read it, so the function must return a copy it does not reuse.
+type PassphraseFunc func() ([]byte, error)
+
+// reader calls f and returns a reader over the passphrase for cmd.Stdin, and
+// a function that zeroes the passphrase once the command has finished.
+func (f PassphraseFunc) reader() (io.Reader, func(), error) {
+	passphrase, err := f()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get passphrase: %w", err)
+	}
+	return bytes.NewReader(passphrase), func() { clear(passphrase) }, nil
+}
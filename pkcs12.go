@@ -0,0 +1,54 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// PKCS12Options holds the settings for ExportPKCS12WithOptions.
+type PKCS12Options struct {
+	// CAFile holds CA certificates to include in the bundle, if any.
+	CAFile string
+
+	// Name is the friendly name of the certificate and key in the bundle.
+	Name string
+
+	// Password protects the bundle. It is fed to openssl on stdin so it never
+	// shows up in the process list.
+	Password io.Reader
+
+	// ExtraArgs are appended verbatim to the pkcs12 command line, e.g. to
+	// select legacy algorithms, unvalidated and unescaped.
+	ExtraArgs []string
+}
+
+// ExportPKCS12 writes the certificate, key and optional CA chain to a
+// password-protected PKCS#12 bundle.
+func ExportPKCS12(certFile, keyFile, caFile, outputFile, password string) error {
+	return ExportPKCS12WithOptions(certFile, keyFile, outputFile, PKCS12Options{
+		CAFile:   caFile,
+		Password: strings.NewReader(password + "\n"),
+	})
+}
+
+// ExportPKCS12WithOptions writes the certificate and key in certFile and
+// keyFile to a PKCS#12 bundle at outputFile.
+func ExportPKCS12WithOptions(certFile, keyFile, outputFile string, opts PKCS12Options) error {
+	if opts.Password == nil {
+		return errors.New("PKCS#12 password is required")
+	}
+
+	args := []string{"pkcs12", "-export", "-in", certFile, "-inkey", keyFile, "-out", outputFile, "-passout", "stdin"}
+	if opts.CAFile != "" {
+		args = append(args, "-certfile", opts.CAFile)
+	}
+	if opts.Name != "" {
+		args = append(args, "-name", opts.Name)
+	}
+	args = append(args, opts.ExtraArgs...)
+
+	cmd := opensslCommand(args...)
+	cmd.Stdin = opts.Password
+	return runCommand(cmd, "Failed to export PKCS#12 bundle")
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package oqsopenssl
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminateProcess asks the process to exit with SIGTERM.
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package oqsopenssl
+
+import "os"
+
+// terminateProcess kills the process, since Windows has no SIGTERM equivalent.
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,63 @@
+package oqsopenssl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultProviderNames are the provider names CheckOQSProvider accepts when
+// Config.ProviderName is empty.
+var DefaultProviderNames = []string{"oqsprovider", "oqs"}
+
+// ErrOQSProviderNotLoaded is wrapped by CheckOQSProvider when the openssl
+// binary does not have the OQS provider loaded.
+var ErrOQSProviderNotLoaded = errors.New("oqs provider not loaded")
+
+// CheckOQSProvider returns nil if the OQS provider is loaded by the configured
+// openssl binary. The expected provider name can be changed for forks through
+// Config.ProviderName.
+func CheckOQSProvider() error {
+	providers, err := ListProviders()
+	if err != nil {
+		return err
+	}
+
+	expected := DefaultProviderNames
+	if name := GetConfig().ProviderName; name != "" {
+		expected = []string{name}
+	}
+	for _, provider := range providers {
+		for _, name := range expected {
+			if provider == name {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s uses providers [%s], expected %s",
+		ErrOQSProviderNotLoaded, opensslPath(), strings.Join(providers, ", "), strings.Join(expected, " or "))
+}
+
+// ListProviders returns the names of the providers loaded by openssl.
+func ListProviders() ([]string, error) {
+	cmd := opensslCommand("list", "-providers")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to list providers")
+	if err != nil {
+		return nil, err
+	}
+	return parseProviderList(output), nil
+}
+
+// parseProviderList extracts provider names from openssl list -providers
+// output, where each provider is a line indented by two spaces followed by
+// more deeply indented attributes.
+func parseProviderList(output string) []string {
+	var providers []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") {
+			providers = append(providers, strings.TrimSpace(line))
+		}
+	}
+	return providers
+}
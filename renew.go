@@ -0,0 +1,73 @@
+package oqsopenssl
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+// RenewCertificate reissues existingCert with the CA certificate and key for
+// a new validity period of days, or openssl's default of 30 days if days is
+// zero, writing it to outputFile. The renewed
+// certificate keeps the subject, SANs (including the SPIFFE ID) and public
+// key of the existing one, so the same private key keeps working. Other
+// extensions, such as the key usage, are not carried over. It requires
+// OpenSSL 3.0 or later.
+func RenewCertificate(existingCert, caCertFile, caKeyFile, outputFile string, days int) error {
+	if err := checkInputFiles(existingCert, caCertFile, caKeyFile); err != nil {
+		return err
+	}
+	info, err := ParseCertificate(existingCert)
+	if err != nil {
+		return err
+	}
+
+	cmd := opensslCommand("x509", "-in", existingCert, "-noout", "-subject", "-nameopt", "compat", "-pubkey")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to read certificate")
+	if err != nil {
+		return err
+	}
+	subject, publicKey, err := parseSubjectAndPublicKey(output)
+	if err != nil {
+		return err
+	}
+
+	pubFile, cleanup, err := writeTempFile("pubkey-*.pem", publicKey)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	input := []string{"-new", "-subj", subject, "-force_pubkey", pubFile}
+	_, err = signCertificate(input, caCertFile, caKeyFile, outputFile, SignOptions{
+		SANs: info.SANs,
+		Days: days,
+	})
+	return err
+}
+
+// parseSubjectAndPublicKey splits the output of x509 -subject -nameopt compat
+// -pubkey into the "/CN=..." subject and the PEM-encoded public key.
+func parseSubjectAndPublicKey(output string) (string, []byte, error) {
+	subject, found := "", false
+	for _, line := range strings.Split(output, "\n") {
+		if value, ok := strings.CutPrefix(line, "subject="); ok {
+			subject, found = strings.TrimSpace(value), true
+			break
+		}
+	}
+	if !found {
+		return "", nil, errors.New("failed to read certificate subject")
+	}
+	// SPIFFE certificates may have an empty subject, which -subj spells "/"
+	if subject == "" {
+		subject = "/"
+	}
+
+	block, _ := pem.Decode([]byte(output))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return "", nil, errors.New("failed to read certificate public key")
+	}
+	return subject, pem.EncodeToMemory(block), nil
+}
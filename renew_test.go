@@ -0,0 +1,60 @@
+package oqsopenssl
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenewCertificateKeepsSANs(t *testing.T) {
+	pki := newTestPKI(t)
+	renewed := filepath.Join(t.TempDir(), "renewed.pem")
+	if err := RenewCertificate(pki.ServerCert, pki.RootCert, pki.RootKey, renewed, 2); err != nil {
+		t.Fatalf("RenewCertificate: %v", err)
+	}
+
+	before, err := GetSANs(pki.ServerCert)
+	if err != nil {
+		t.Fatalf("GetSANs: %v", err)
+	}
+	after, err := GetSANs(renewed)
+	if err != nil {
+		t.Fatalf("GetSANs: %v", err)
+	}
+	for _, sanType := range []SANType{SANDNS, SANIP, SANURI} {
+		if !hasSANType(before, sanType) {
+			t.Fatalf("test certificate has no %s SAN: %v", sanType, before)
+		}
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("renewed SANs = %v, want %v", after, before)
+	}
+
+	original, err := ParseCertificate(pki.ServerCert)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	info, err := ParseCertificate(renewed)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	if original.SPIFFEID == "" || info.SPIFFEID != original.SPIFFEID {
+		t.Errorf("renewed SPIFFE ID = %q, want %q", info.SPIFFEID, original.SPIFFEID)
+	}
+	if err := ValidateCertificate(renewed, pki.RootCert); err != nil {
+		t.Errorf("renewed certificate does not validate: %v", err)
+	}
+	if ok, err := KeyMatchesCertificate(pki.ServerKey, renewed); err != nil || !ok {
+		t.Errorf("KeyMatchesCertificate = %v, %v; want true", ok, err)
+	}
+}
+
+// hasSANType reports whether sans has an entry of type sanType.
+func hasSANType(sans []SAN, sanType SANType) bool {
+	for _, san := range sans {
+		if san.Type == sanType {
+			return true
+		}
+	}
+	return false
+}
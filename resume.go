@@ -0,0 +1,102 @@
+package oqsopenssl
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// sessionTicketWait bounds how long HandshakeResumption keeps the first
+// connection open after the handshake, waiting for a session to save. TLS 1.3
+// servers send their session tickets only after the handshake.
+const sessionTicketWait = time.Second
+
+// HandshakeResumption checks that sessions with the server at address can be
+// resumed. It connects once with s_client -sess_out to obtain a session,
+// then connects again offering it with -sess_in, and returns the parameters
+// of the second handshake. HandshakeInfo.Reused reports whether the session
+// was resumed, e.g. to check that resumption works with a PQ group. If the
+// server sends no session to resume, as with WithoutSessionTickets, the
+// parameters of the first handshake are returned instead, with Reused unset.
+// The session file is removed before HandshakeResumption returns.
+func HandshakeResumption(address string, opts ClientOptions) (HandshakeInfo, error) {
+	sessionFile, cleanup, err := writeTempFile("session-*.pem", nil)
+	if err != nil {
+		return HandshakeInfo{}, err
+	}
+	defer cleanup()
+
+	info, saved, err := saveSession(address, opts, sessionFile)
+	if err != nil || !saved {
+		if err == nil {
+			logf("Server at %s did not send a session to resume", address)
+		}
+		return info, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout())
+	defer cancel()
+	return handshakeOnce(ctx, address, opts, sessionFile)
+}
+
+// saveSession connects to address and writes the session the server hands
+// out to sessionFile. It returns the parameters of the handshake and whether
+// a session was saved.
+func saveSession(address string, opts ClientOptions, sessionFile string) (HandshakeInfo, bool, error) {
+	opts.ExtraArgs = append(append([]string(nil), opts.ExtraArgs...), "-sess_out", sessionFile)
+	cmd, stdin, stdout, err := StartClientWithOptions(address, opts)
+	if err != nil {
+		return HandshakeInfo{}, false, err
+	}
+	replay, err := WaitHandshake(stdout, handshakeTimeout())
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return HandshakeInfo{}, false, err
+	}
+
+	type parseResult struct {
+		info HandshakeInfo
+		err  error
+	}
+	parsed := make(chan parseResult, 1)
+	go func() {
+		info, err := ParseHandshake(replay)
+		parsed <- parseResult{info, err}
+	}()
+
+	// Keep the connection open, so that s_client reads tickets sent after
+	// the handshake, until a session has been saved or s_client exits
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(sessionTicketWait)
+	var res parseResult
+	exited := false
+wait:
+	for !sessionSaved(sessionFile) {
+		select {
+		case res = <-parsed:
+			exited = true
+			break wait
+		case <-timeout:
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	// At EOF on stdin s_client closes the connection and exits, after it has
+	// finished writing the session file
+	_ = stdin.Close()
+	if !exited {
+		res = <-parsed
+	}
+	_ = cmd.Wait()
+	return res.info, sessionSaved(sessionFile), res.err
+}
+
+// sessionSaved reports whether s_client has written a session to
+// sessionFile.
+func sessionSaved(sessionFile string) bool {
+	info, err := os.Stat(sessionFile)
+	return err == nil && info.Size() > 0
+}
@@ -0,0 +1,56 @@
+package oqsopenssl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SANType is the kind of a subject alternative name entry.
+type SANType string
+
+const (
+	SANDNS   SANType = "DNS"
+	SANIP    SANType = "IP"
+	SANURI   SANType = "URI"
+	SANEmail SANType = "email"
+)
+
+// SAN is a single subject alternative name entry, such as a SPIFFE ID URI or
+// a service DNS name.
+type SAN struct {
+	Type  SANType
+	Value string
+}
+
+// String returns the entry in openssl's "TYPE:value" notation.
+func (s SAN) String() string {
+	return fmt.Sprintf("%s:%s", s.Type, s.Value)
+}
+
+// subjectAltName builds the value of a subjectAltName extension from the given
+// entries, optionally preceded by a SPIFFE ID URI. It returns an empty string
+// if there are no entries.
+func subjectAltName(spiffeID string, sans []SAN) (string, error) {
+	if spiffeID != "" {
+		sans = append([]SAN{{Type: SANURI, Value: spiffeID}}, sans...)
+	}
+
+	entries := make([]string, 0, len(sans))
+	for _, san := range sans {
+		if san.Value == "" || strings.ContainsAny(san.Value, ",\n") {
+			return "", fmt.Errorf("invalid %s subject alternative name %q", san.Type, san.Value)
+		}
+		switch san.Type {
+		case SANDNS, SANURI, SANEmail:
+		case SANIP:
+			if net.ParseIP(san.Value) == nil {
+				return "", fmt.Errorf("invalid IP subject alternative name %q", san.Value)
+			}
+		default:
+			return "", fmt.Errorf("unsupported subject alternative name type %q", san.Type)
+		}
+		entries = append(entries, san.String())
+	}
+	return strings.Join(entries, ","), nil
+}
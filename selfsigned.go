@@ -0,0 +1,83 @@
+package oqsopenssl
+
+import (
+	"errors"
+	"time"
+)
+
+// SelfSignedOptions holds the settings for GenerateSelfSigned.
+type SelfSignedOptions struct {
+	// Algorithm is the key algorithm for the new key, e.g. "mldsa44".
+	Algorithm string
+
+	// Subject is the distinguished name passed to -subj, e.g. "/CN=localhost".
+	Subject string
+
+	// SPIFFEID is written to the certificate as a URI subjectAltName.
+	SPIFFEID string
+
+	// SANs are additional subjectAltName entries.
+	SANs []SAN
+
+	// KeyFile and CertFile are where the key and certificate are written.
+	KeyFile  string
+	CertFile string
+
+	// Days is the validity period of the certificate. If zero, openssl's
+	// default of 30 days is used. It must not be negative.
+	Days int
+
+	// Usage sets the keyUsage and extendedKeyUsage of the certificate.
+	// If empty, ServerUsage is used.
+	Usage Usage
+
+	// ExtraArgs are appended verbatim to the req command line, unvalidated
+	// and unescaped.
+	ExtraArgs []string
+}
+
+// GenerateSelfSigned generates a new key and a self-signed end-entity
+// certificate for it in one step, writing them to opts.KeyFile and
+// opts.CertFile. Unlike GenerateRootCertificate, the certificate is marked as
+// not being a CA, so it is meant to be trusted directly, e.g. for local
+// testing.
+func GenerateSelfSigned(opts SelfSignedOptions) error {
+	if opts.KeyFile == "" || opts.CertFile == "" {
+		return errors.New("key and certificate paths are required")
+	}
+	altName, err := subjectAltName(opts.SPIFFEID, opts.SANs)
+	if err != nil {
+		return err
+	}
+	validity, err := validityArgs(opts.Days, 0, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	usage := opts.Usage
+	if len(usage.KeyUsage) == 0 && len(usage.ExtKeyUsage) == 0 {
+		usage = ServerUsage()
+	}
+
+	args := []string{
+		"req",
+		"-nodes",
+		"-x509",
+		"-newkey", opts.Algorithm,
+		"-keyout", opts.KeyFile,
+		"-out", opts.CertFile,
+		"-subj", opts.Subject,
+	}
+	args = append(args, validity...)
+	// The default configuration marks req -x509 output as a CA
+	args = append(args, "-addext", "basicConstraints=critical,CA:FALSE")
+	for _, ext := range usage.extensions() {
+		args = append(args, "-addext", ext)
+	}
+	if altName != "" {
+		args = append(args, "-addext", "subjectAltName="+altName)
+	}
+	args = append(args, opts.ExtraArgs...)
+
+	cmd := opensslCommand(args...)
+	return runCommand(cmd, "Failed to generate self-signed certificate")
+}
@@ -0,0 +1,55 @@
+package oqsopenssl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// VerifySPIFFEID checks the SPIFFE ID in the URI SAN of the certificate in
+// certFile against expectedID. A full ID such as "spiffe://example.org/web"
+// must match exactly, while a bare trust domain such as "spiffe://example.org"
+// accepts any ID in that trust domain. The error is ErrNoSPIFFEID if the
+// certificate has no SPIFFE ID, and otherwise wraps ErrTrustDomainMismatch or
+// ErrSPIFFEIDMismatch.
+func VerifySPIFFEID(certFile, expectedID string) error {
+	expected, err := parseSPIFFEID(expectedID)
+	if err != nil {
+		return err
+	}
+
+	info, err := ParseCertificate(certFile)
+	if err != nil {
+		return err
+	}
+	if info.SPIFFEID == "" {
+		return ErrNoSPIFFEID
+	}
+	actual, err := parseSPIFFEID(info.SPIFFEID)
+	if err != nil {
+		return err
+	}
+
+	if actual.Host != expected.Host {
+		return fmt.Errorf("%w: got %s, want %s", ErrTrustDomainMismatch, actual.Host, expected.Host)
+	}
+	if expected.Path != "" && actual.Path != expected.Path {
+		return fmt.Errorf("%w: got %s, want %s", ErrSPIFFEIDMismatch, info.SPIFFEID, expectedID)
+	}
+	return nil
+}
+
+// parseSPIFFEID parses id as a spiffe URI and lower-cases its trust domain,
+// which SPIFFE IDs compare case-insensitively. A trailing slash on a bare
+// trust domain is dropped.
+func parseSPIFFEID(id string) (*url.URL, error) {
+	u, err := url.Parse(id)
+	if err != nil || u.Scheme != "spiffe" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SPIFFE ID %q", id)
+	}
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	return u, nil
+}
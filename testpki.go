@@ -0,0 +1,111 @@
+package oqsopenssl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TestPKI is a throwaway PKI created by NewTestPKI: a root CA and a server and
+// a client certificate issued by it, all stored in Dir.
+type TestPKI struct {
+	// Dir is the temporary directory holding all files.
+	Dir string
+
+	// RootCert and RootKey are the root CA, with subject "/CN=Test Root CA".
+	RootCert string
+	RootKey  string
+
+	// ServerCert and ServerKey are a server leaf for localhost, with the
+	// SANs localhost, 127.0.0.1 and ::1 and the SPIFFE ID
+	// spiffe://example.org/server.
+	ServerCert string
+	ServerKey  string
+
+	// ClientCert and ClientKey are a client leaf with the SPIFFE ID
+	// spiffe://example.org/client.
+	ClientCert string
+	ClientKey  string
+}
+
+// NewTestPKI creates a root CA and server and client leaves in a new
+// temporary directory, with every key using algorithm, e.g. "mldsa44" or
+// "ED25519"; "EC" keys use the P-256 curve. The certificates are valid for
+// one day. Call Cleanup to remove the
+// directory once the PKI is no longer needed.
+func NewTestPKI(algorithm string) (*TestPKI, error) {
+	// A dry run reports the first command without creating the directory
+	dir := dryRunTempPath("oqsopenssl-pki-*")
+	if !dryRun() {
+		var err error
+		if dir, err = os.MkdirTemp("", "oqsopenssl-pki-*"); err != nil {
+			return nil, fmt.Errorf("failed to create PKI directory: %w", err)
+		}
+	}
+	pki := &TestPKI{
+		Dir:        dir,
+		RootCert:   filepath.Join(dir, "root.pem"),
+		RootKey:    filepath.Join(dir, "root.key"),
+		ServerCert: filepath.Join(dir, "server.pem"),
+		ServerKey:  filepath.Join(dir, "server.key"),
+		ClientCert: filepath.Join(dir, "client.pem"),
+		ClientKey:  filepath.Join(dir, "client.key"),
+	}
+	if err := pki.generate(algorithm); err != nil {
+		if !dryRun() {
+			pki.Cleanup()
+		}
+		return nil, err
+	}
+	return pki, nil
+}
+
+// generate writes the files of the PKI.
+func (p *TestPKI) generate(algorithm string) error {
+	var keyOpts KeyOptions
+	if strings.EqualFold(algorithm, "EC") {
+		keyOpts.ECCurve = "P-256"
+	}
+	for _, keyFile := range []string{p.RootKey, p.ServerKey, p.ClientKey} {
+		if err := GeneratePrivateKeyWithOptions(context.Background(), algorithm, keyFile, keyOpts); err != nil {
+			return err
+		}
+	}
+	if err := GenerateRootCertificate(p.RootKey, p.RootCert, "/CN=Test Root CA", "", "", 1); err != nil {
+		return err
+	}
+
+	leaves := []struct {
+		subject, keyFile, certFile string
+		opts                       SignOptions
+	}{
+		{"/CN=localhost", p.ServerKey, p.ServerCert, SignOptions{
+			SPIFFEID: "spiffe://example.org/server",
+			SANs:     []SAN{{Type: SANDNS, Value: "localhost"}, {Type: SANIP, Value: "127.0.0.1"}, {Type: SANIP, Value: "::1"}},
+			Usage:    ServerUsage(),
+		}},
+		{"/CN=client", p.ClientKey, p.ClientCert, SignOptions{
+			SPIFFEID: "spiffe://example.org/client",
+			Usage:    ClientUsage(),
+		}},
+	}
+	csrFile := filepath.Join(p.Dir, "leaf.csr")
+	defer os.Remove(csrFile)
+	for _, leaf := range leaves {
+		if err := GenerateCSRFromKey(leaf.keyFile, csrFile, leaf.subject, "", ""); err != nil {
+			return err
+		}
+		leaf.opts.Days = 1
+		if err := SignCertificateWithOptions(csrFile, p.RootCert, p.RootKey, leaf.certFile, leaf.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup removes the PKI directory and everything in it.
+func (p *TestPKI) Cleanup() {
+	os.RemoveAll(p.Dir)
+}
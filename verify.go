@@ -0,0 +1,137 @@
+package oqsopenssl
+
+import (
+	"errors"
+)
+
+// Common X509_V_ERR codes reported in VerifyResult.Code.
+const (
+	VerifyOK                        = 0
+	VerifyErrUnableToGetIssuer      = 2
+	VerifyErrUnableToGetCRL         = 3
+	VerifyErrCertNotYetValid        = 9
+	VerifyErrCertHasExpired         = 10
+	VerifyErrDepthZeroSelfSigned    = 18
+	VerifyErrSelfSignedCertInChain  = 19
+	VerifyErrUnableToGetLocalIssuer = 20
+	VerifyErrUnableToVerifyLeafSig  = 21
+	VerifyErrCertRevoked            = 23
+	VerifyErrInvalidCA              = 24
+	VerifyErrPathLengthExceeded     = 25
+	VerifyErrInvalidPurpose         = 26
+	VerifyErrHostnameMismatch       = 62
+)
+
+// Purpose is a certificate purpose checked by openssl verify, named as
+// openssl's -purpose option expects.
+type Purpose string
+
+const (
+	// PurposeSSLServer requires a certificate usable by a TLS server, e.g.
+	// one whose extendedKeyUsage includes serverAuth.
+	PurposeSSLServer Purpose = "sslserver"
+	// PurposeSSLClient requires a certificate usable by a TLS client, e.g.
+	// one whose extendedKeyUsage includes clientAuth.
+	PurposeSSLClient Purpose = "sslclient"
+)
+
+// VerifyResult is the outcome of openssl verify.
+type VerifyResult struct {
+	// Valid reports whether the certificate verified successfully.
+	Valid bool
+	// Code is the X509_V_ERR code of the failure, or VerifyOK.
+	Code int
+	// Reason is openssl's description of the failure, e.g.
+	// "certificate has expired".
+	Reason string
+	// Depth is the position of the failing certificate in the chain.
+	Depth int
+	// Subject is the subject of the failing certificate, if reported.
+	Subject string
+}
+
+// VerifyCertificate verifies certFile against the CA certificates in caFile,
+// using the optional intermediates to build the chain. A certificate that
+// fails verification is reported through the result rather than the error,
+// which is only returned when openssl could not perform the check.
+func VerifyCertificate(certFile, caFile string, intermediates []string) (VerifyResult, error) {
+	return VerifyCertificateWithOptions(certFile, VerifyOptions{
+		CAFile:        caFile,
+		Intermediates: intermediates,
+	})
+}
+
+// VerifyCertificateWithOptions is like VerifyCertificate but takes the trust
+// anchors and intermediates from opts.
+func VerifyCertificateWithOptions(certFile string, opts VerifyOptions) (VerifyResult, error) {
+	err := ValidateCertificateWithOptions(certFile, opts)
+	if err == nil {
+		return VerifyResult{Valid: true, Code: VerifyOK}, nil
+	}
+
+	var verifyErr *VerifyError
+	if errors.As(err, &verifyErr) {
+		return VerifyResult{
+			Code:    verifyErr.Code,
+			Reason:  verifyErr.Reason,
+			Depth:   verifyErr.Depth,
+			Subject: verifyErr.Subject,
+		}, nil
+	}
+	return VerifyResult{}, err
+}
+
+// ValidateCertificatePurpose is like ValidateCertificate but also checks that
+// certFile may be used for purpose. If it may not, the returned error matches
+// ErrInvalidPurpose.
+func ValidateCertificatePurpose(certFile, caFile string, purpose Purpose) error {
+	return ValidateCertificateWithOptions(certFile, VerifyOptions{
+		CAFile:  caFile,
+		Purpose: purpose,
+	})
+}
+
+// ValidateCertificateBytes is like ValidateCertificate but takes the
+// PEM-encoded certificate and CA certificates from memory. They are written
+// to temporary files that are removed before it returns. If verification
+// fails, the returned error wraps a *VerifyError naming the failing link.
+func ValidateCertificateBytes(cert, caCert []byte) error {
+	return ValidateCertificateChainBytes(cert, caCert, nil)
+}
+
+// ValidateCertificateChainBytes is like ValidateCertificateChain but takes
+// the PEM-encoded certificate, CA certificates and intermediates from memory.
+func ValidateCertificateChainBytes(cert, caCert []byte, intermediates [][]byte) error {
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	writeFile := func(data []byte) (string, error) {
+		path, cleanup, err := writeTempFile("verify-*.pem", data)
+		if err != nil {
+			return "", err
+		}
+		cleanups = append(cleanups, cleanup)
+		return path, nil
+	}
+
+	certFile, err := writeFile(cert)
+	if err != nil {
+		return err
+	}
+	caFile, err := writeFile(caCert)
+	if err != nil {
+		return err
+	}
+	intermediateFiles := make([]string, 0, len(intermediates))
+	for _, intermediate := range intermediates {
+		path, err := writeFile(intermediate)
+		if err != nil {
+			return err
+		}
+		intermediateFiles = append(intermediateFiles, path)
+	}
+	return ValidateCertificateChain(certFile, caFile, intermediateFiles)
+}
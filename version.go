@@ -0,0 +1,53 @@
+package oqsopenssl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionString returns the output of openssl version, e.g.
+// "OpenSSL 3.0.17 1 Jul 2025 (Library: OpenSSL 3.0.17 1 Jul 2025)".
+func VersionString() (string, error) {
+	cmd := opensslCommand("version")
+	output, err := runCommandOutput(context.Background(), cmd, "Failed to get OpenSSL version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// Version returns the semantic version of the openssl binary.
+func Version() (major, minor, patch int, err error) {
+	version, err := VersionString()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parseVersion(version)
+}
+
+// parseVersion extracts the numeric version from openssl version output.
+// Suffixes such as "-dev" or the letter of 1.1.1 releases are ignored.
+func parseVersion(version string) (major, minor, patch int, err error) {
+	fields := strings.Fields(version)
+	if len(fields) < 2 {
+		return 0, 0, 0, fmt.Errorf("unexpected OpenSSL version output %q", version)
+	}
+
+	numbers := strings.SplitN(fields[1], ".", 3)
+	if len(numbers) != 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected OpenSSL version %q", fields[1])
+	}
+	parts := make([]int, 3)
+	for i, number := range numbers {
+		if end := strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			number = number[:end]
+		}
+		parts[i], err = strconv.Atoi(number)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("unexpected OpenSSL version %q", fields[1])
+		}
+	}
+	return parts[0], parts[1], parts[2], nil
+}